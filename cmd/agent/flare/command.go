@@ -7,8 +7,10 @@
 package flare
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/DataDog/dd-agent-comp-experiments/cmd/agent/root"
 	"github.com/DataDog/dd-agent-comp-experiments/cmd/common"
@@ -32,15 +34,17 @@ func command(_ *cobra.Command, args []string) error {
 	)
 }
 
-func getFlareRemote(ipcclient ipcclient.Component) (string, error) {
+func getFlareRemote(client ipcclient.Component) (string, error) {
+	// creating a flare can take longer than the client's default timeout, so
+	// wait as long as the Agent allows, plus a little time for the response
+	ctx, cancel := context.WithTimeout(context.Background(), flare.RemoteTimeout+10*time.Second)
+	defer cancel()
+
 	var content map[string]string
-	err := ipcclient.GetJSON("/agent/flare", &content)
+	err := client.PostJSON(ctx, "/agent/flare", nil, &content)
 	if err != nil {
 		return "", err
 	}
-	if msg, found := content["error"]; found {
-		return "", fmt.Errorf("Error from Agent: %s", msg)
-	}
 
 	if filename, found := content["filename"]; found {
 		return filename, nil
@@ -48,9 +52,9 @@ func getFlareRemote(ipcclient ipcclient.Component) (string, error) {
 	return "", errors.New("No filename received from Agent")
 }
 
-func flareCmd(ipcclient ipcclient.Component, flare flare.Component) error {
-	archiveFile, err := getFlareRemote(ipcclient)
-	if err != nil {
+func flareCmd(client ipcclient.Component, flare flare.Component) error {
+	archiveFile, err := getFlareRemote(client)
+	if errors.Is(err, ipcclient.ErrAgentNotRunning) {
 		fmt.Printf("Could not contact agent: %s\n", err)
 		fmt.Printf("Proceeding with local flare.\n")
 		archiveFile, err = flare.CreateFlare()
@@ -0,0 +1,49 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package gui implements the `agent gui` command.
+package gui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/DataDog/dd-agent-comp-experiments/cmd/agent/root"
+	"github.com/DataDog/dd-agent-comp-experiments/cmd/common"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/ipc/ipcclient"
+	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/fxapps"
+	"github.com/spf13/cobra"
+)
+
+var (
+	Cmd = &cobra.Command{
+		Use:   "gui",
+		Short: "Get a one-time URL to open the running Agent's status page in a browser",
+		RunE:  command,
+	}
+)
+
+func command(_ *cobra.Command, args []string) error {
+	return fxapps.OneShot(guiCmd,
+		common.SharedOptions(root.ConfFilePath, true),
+	)
+}
+
+func guiCmd(client ipcclient.Component) error {
+	var content map[string]string
+	err := client.PostJSON(context.Background(), "/agent/login/intent?next=/agent/gui", nil, &content)
+	if err != nil {
+		return err
+	}
+
+	url, found := content["url"]
+	if !found {
+		return errors.New("No login URL received from Agent")
+	}
+
+	fmt.Printf("Open this URL in a browser within five minutes.  It can only be used once.\n%s\n", url)
+	return nil
+}
@@ -7,12 +7,20 @@
 package health
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/DataDog/dd-agent-comp-experiments/cmd/agent/root"
 	"github.com/DataDog/dd-agent-comp-experiments/cmd/common"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/health"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/ipc/ipcclient"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/ipc/ipcpb"
 	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/fxapps"
 	"github.com/spf13/cobra"
 )
@@ -23,9 +31,21 @@ var (
 		Short: "Get the health of Agent's components",
 		RunE:  command,
 	}
+
+	// watch is the value of the --watch flag
+	watch bool
 )
 
+func init() {
+	Cmd.Flags().BoolVarP(&watch, "watch", "w", false, "print the health again each time it changes, until interrupted")
+}
+
 func command(_ *cobra.Command, args []string) error {
+	if watch {
+		return fxapps.OneShot(watchCmd,
+			common.SharedOptions(root.ConfFilePath, true),
+		)
+	}
 	return fxapps.OneShot(healthCmd,
 		common.SharedOptions(root.ConfFilePath, true),
 	)
@@ -33,7 +53,7 @@ func command(_ *cobra.Command, args []string) error {
 
 func getHealthRemote(ipcclient ipcclient.Component) (map[string]health.ComponentHealth, error) {
 	var content map[string]health.ComponentHealth
-	err := ipcclient.GetJSON("/agent/health", &content)
+	err := ipcclient.GetJSON(context.Background(), "/agent/health", &content)
 	if err != nil {
 		return nil, err
 	}
@@ -41,20 +61,108 @@ func getHealthRemote(ipcclient ipcclient.Component) (map[string]health.Component
 	return content, nil
 }
 
-func healthCmd(ipcclient ipcclient.Component, health health.Component) error {
+func healthCmd(ipcclient ipcclient.Component) error {
 	resp, err := getHealthRemote(ipcclient)
 	if err != nil {
 		return err
 	}
 
-	for component, h := range resp {
-		fmt.Printf("%s: ", component)
-		if h.Healthy {
-			fmt.Printf("OK\n")
-		} else {
-			fmt.Printf("UNHEALTHY (%s)\n", h.Message)
+	// only a failure of a critical component is considered a failure of the
+	// agent as a whole
+	if printHealth(resp) == health.Unhealthy {
+		return errors.New("Agent is unhealthy")
+	}
+	return nil
+}
+
+// watchCmd prints the health of the agent's components each time it changes,
+// until interrupted.
+func watchCmd(client ipcclient.Component) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	conn, err := client.GRPCConn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	stream, err := ipcpb.NewHealthClient(conn).WatchHealth(ctx, &ipcpb.WatchHealthRequest{})
+	if err != nil {
+		return err
+	}
+
+	for first := true; ; first = false {
+		update, err := stream.Recv()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		if !first {
+			fmt.Printf("\n")
+		}
+		fmt.Printf("--- %s\n", time.Now().Format(time.RFC3339))
+		printHealth(health.FromProto(update))
+	}
+}
+
+// printHealth prints the health of each component and of the agent as a
+// whole, returning the latter.
+func printHealth(resp map[string]health.ComponentHealth) health.Status {
+	components := make([]string, 0, len(resp))
+	for component := range resp {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+
+	for _, component := range components {
+		h := resp[component]
+		fmt.Printf("%s", component)
+		if h.Critical {
+			fmt.Printf(" (critical)")
+		}
+		fmt.Printf(": %s", colorize(h.Status))
+		if h.State != health.Ready {
+			fmt.Printf(" [%s]", h.State)
+		}
+		if h.Message != "" {
+			fmt.Printf(" (%s)", h.Message)
 		}
+		if h.Restarts > 0 {
+			fmt.Printf(" [restarted %d times]", h.Restarts)
+		}
+		fmt.Printf("\n")
 	}
 
-	return nil
+	agentHealth := health.AggregateHealth(resp)
+	fmt.Printf("\nAgent: %s\n", colorize(agentHealth))
+	return agentHealth
+}
+
+// ansi color codes for each health status
+var statusColors = map[health.Status]string{
+	health.Healthy:   "\033[32m", // green
+	health.Degraded:  "\033[33m", // yellow
+	health.Unhealthy: "\033[31m", // red
+}
+
+// colorize formats the status in upper case, colored if stdout is a terminal.
+func colorize(status health.Status) string {
+	label := strings.ToUpper(status.String())
+	if !isTerminal(os.Stdout) {
+		return label
+	}
+	return statusColors[status] + label + "\033[0m"
+}
+
+// isTerminal determines whether the given file is a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
 }
@@ -0,0 +1,38 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package health
+
+import (
+	"testing"
+
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/health"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/ipc/ipcclient"
+	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/comptest"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+)
+
+func TestGetHealthRemote(t *testing.T) {
+	var client ipcclient.Component
+	healthReg := health.NewCriticalRegistration("comp/thing")
+	comptest.FxTest(t,
+		core.MockBundle,
+		fx.Supply(healthReg),
+		fx.Invoke(func(health.Component) {}),
+		fx.Populate(&client),
+	).WithRunningApp(func() {
+		healthReg.Handle.SetStarting()
+		healthReg.Handle.SetReady()
+		healthReg.Handle.SetDegraded("slow")
+
+		content, err := getHealthRemote(client)
+		require.NoError(t, err)
+		require.Equal(t, map[string]health.ComponentHealth{
+			"comp/thing": {State: health.Ready, Status: health.Degraded, Critical: true, Message: "slow"},
+		}, content)
+	})
+}
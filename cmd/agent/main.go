@@ -9,6 +9,7 @@ import (
 	"os"
 
 	"github.com/DataDog/dd-agent-comp-experiments/cmd/agent/flare"
+	"github.com/DataDog/dd-agent-comp-experiments/cmd/agent/gui"
 	"github.com/DataDog/dd-agent-comp-experiments/cmd/agent/health"
 	"github.com/DataDog/dd-agent-comp-experiments/cmd/agent/root"
 	"github.com/DataDog/dd-agent-comp-experiments/cmd/agent/run"
@@ -21,6 +22,7 @@ func main() {
 		health.Cmd,
 		flare.Cmd,
 		status.Cmd,
+		gui.Cmd,
 	)
 	if err := cmd.Execute(); err != nil {
 		os.Exit(-1)
@@ -7,7 +7,11 @@
 package status
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"net/url"
 
 	"github.com/DataDog/dd-agent-comp-experiments/cmd/agent/root"
 	"github.com/DataDog/dd-agent-comp-experiments/cmd/common"
@@ -20,21 +24,50 @@ import (
 
 var (
 	Cmd = &cobra.Command{
-		Use:   "status [section]",
-		Short: "Get the running Agent's status, optionally showing only a single section",
+		Use:   "status [section..]",
+		Short: "Get the running Agent's status, optionally showing only some sections",
 		RunE:  command,
-		Args:  cobra.MaximumNArgs(1),
 	}
+
+	// listFlag is set by --list
+	listFlag bool
+
+	// jsonFlag is set by --json
+	jsonFlag bool
+
+	// formatFlag is set by --format
+	formatFlag string
 )
 
+func init() {
+	Cmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "output status as JSON (same as --format json)")
+	Cmd.Flags().StringVarP(&formatFlag, "format", "f", status.TextFormat, "output format: text, json, or html")
+	Cmd.Flags().BoolVarP(&listFlag, "list", "l", false, "list the available status sections")
+}
+
 type cmdArgs struct {
-	section string
+	sections []string
+	format   string
 }
 
 func command(_ *cobra.Command, args []string) error {
-	var cmdArgs cmdArgs
-	if len(args) > 0 {
-		cmdArgs.section = args[0]
+	if listFlag {
+		return fxapps.OneShot(listCmd,
+			common.SharedOptions(root.ConfFilePath, true),
+		)
+	}
+
+	cmdArgs := cmdArgs{sections: args}
+
+	cmdArgs.format = formatFlag
+	if jsonFlag {
+		cmdArgs.format = status.JSONFormat
+	}
+
+	switch cmdArgs.format {
+	case status.TextFormat, status.JSONFormat, status.HTMLFormat:
+	default:
+		return fmt.Errorf("Unsupported format %q", cmdArgs.format)
 	}
 
 	return fxapps.OneShot(statusCmd,
@@ -43,14 +76,31 @@ func command(_ *cobra.Command, args []string) error {
 	)
 }
 
-func getStatusRemote(ipcclient ipcclient.Component, section string) (string, error) {
-	var content map[string]string
-	path := "/agent/status"
-	if section != "" {
-		path = fmt.Sprintf("%s?section=%s", path, section)
+func getStatusRemote(ipcclient ipcclient.Component, sections []string, format string) (string, error) {
+	query := url.Values{}
+	query.Set("format", format)
+	for _, section := range sections {
+		query.Add("section", section)
 	}
+	path := "/agent/status?" + query.Encode()
+
+	if format == status.JSONFormat {
+		var content map[string]json.RawMessage
+		err := ipcclient.GetJSON(context.Background(), path, &content)
+		if err != nil {
+			return "", err
+		}
 
-	err := ipcclient.GetJSON(path, &content)
+		var bldr bytes.Buffer
+		err = json.Indent(&bldr, content["sections"], "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return bldr.String() + "\n", nil
+	}
+
+	var content map[string]string
+	err := ipcclient.GetJSON(context.Background(), path, &content)
 	if err != nil {
 		return "", err
 	}
@@ -58,8 +108,8 @@ func getStatusRemote(ipcclient ipcclient.Component, section string) (string, err
 	return content["status"], nil
 }
 
-func statusCmd(ipcclient ipcclient.Component, status status.Component, cmdArgs cmdArgs) error {
-	statusStr, err := getStatusRemote(ipcclient, cmdArgs.section)
+func statusCmd(ipcclient ipcclient.Component, cmdArgs cmdArgs) error {
+	statusStr, err := getStatusRemote(ipcclient, cmdArgs.sections, cmdArgs.format)
 	if err != nil {
 		return err
 	}
@@ -67,3 +117,16 @@ func statusCmd(ipcclient ipcclient.Component, status status.Component, cmdArgs c
 	fmt.Printf("%s", statusStr)
 	return nil
 }
+
+func listCmd(ipcclient ipcclient.Component) error {
+	var content map[string][]status.SectionInfo
+	err := ipcclient.GetJSON(context.Background(), "/agent/status/sections", &content)
+	if err != nil {
+		return err
+	}
+
+	for _, info := range content["sections"] {
+		fmt.Printf("%s: %s\n", info.Name, info.Description)
+	}
+	return nil
+}
@@ -27,8 +27,9 @@ type autoDiscovery struct {
 	// log is the log component
 	log log.Component
 
-	// configChangeTx connects to receivers of messages about config additions/removals
-	configChangeTx subscriptions.Transmitter[ConfigChange]
+	// configChangeTx connects to receivers of messages about config additions/removals,
+	// and tracks the scheduled configs
+	configChangeTx *subscriptions.StateTransmitter[string, *Config, ConfigChange]
 }
 
 type dependencies struct {
@@ -41,40 +42,58 @@ type dependencies struct {
 }
 
 func newAD(deps dependencies) (Component, health.Registration) {
-	healthReg := health.NewRegistration(componentName)
+	healthReg := health.NewCriticalRegistration(componentName)
+	configChangeTx := subscriptions.NewStateTransmitter(deps.Pub.Transmitter(componentName),
+		func(cfg *Config) string { return cfg.Name },
+		func(cfg *Config, isAdd bool) ConfigChange { return ConfigChange{IsScheduled: isAdd, Config: cfg} })
 	ad := &autoDiscovery{
 		log:            deps.Log,
-		configChangeTx: deps.Pub.Transmitter(),
+		configChangeTx: configChangeTx,
 	}
 	if deps.Params.ShouldStart() {
 		actor := actor.New()
+		actor.LogFailures(deps.Log)
 		actor.HookLifecycle(deps.Lc, ad.run)
 		actor.MonitorLiveness(healthReg.Handle, time.Second)
 	}
 	return ad, healthReg
 }
 
-func (ad *autoDiscovery) run(ctx context.Context, alive <-chan struct{}) {
-	scheduled := []*Config{}
+func (ad *autoDiscovery) run(ctx context.Context, alive <-chan struct{}) error {
 	tkr := time.NewTicker(time.Second)
 	for {
 		select {
 		case <-tkr.C:
+			scheduled := ad.configChangeTx.Items()
 			if len(scheduled) == 0 || rand.Intn(2) == 0 {
 				cfg := &Config{Name: fmt.Sprintf("cfg-%d", rand.Int63())}
-				scheduled = append(scheduled, cfg)
 				ad.log.Debug("scheduling", cfg.Name)
-				ad.configChangeTx.Notify(ConfigChange{IsScheduled: true, Config: cfg})
+				ad.configChangeTx.Add(cfg)
 			} else {
+				// map iteration order is not uniformly random, so choose by index
 				i := rand.Intn(len(scheduled))
-				cfg := scheduled[i]
-				scheduled = append(scheduled[:i], scheduled[i+1:]...)
-				ad.log.Debug("unscheduling", cfg.Name)
-				ad.configChangeTx.Notify(ConfigChange{IsScheduled: false, Config: cfg})
+				for _, cfg := range scheduled {
+					if i == 0 {
+						ad.log.Debug("unscheduling", cfg.Name)
+						ad.configChangeTx.Remove(cfg)
+						break
+					}
+					i--
+				}
 			}
 		case <-alive:
 		case <-ctx.Done():
-			return
+			return nil
 		}
 	}
 }
+
+// Subscribe implements Component#Subscribe.
+func (ad *autoDiscovery) Subscribe(opts ...subscriptions.Option) (subscriptions.Receiver[ConfigChange], error) {
+	return ad.configChangeTx.Subscribe(opts...)
+}
+
+// Unsubscribe implements Component#Unsubscribe.
+func (ad *autoDiscovery) Unsubscribe(rx subscriptions.Receiver[ConfigChange]) {
+	ad.configChangeTx.Unsubscribe(rx)
+}
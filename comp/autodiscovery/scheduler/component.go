@@ -7,10 +7,13 @@
 // configuration to its subscribers.
 //
 // Subscribe to the scheduler by providing a
-// `subscriptions.Subscription[scheduler.ConfigChange]`.
+// `subscriptions.Subscription[scheduler.ConfigChange]`.  Components that
+// subscribe at runtime, with Subscribe, first receive a ConfigChange for each
+// config that is already scheduled.
 package scheduler
 
 import (
+	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/subscriptions"
 	"go.uber.org/fx"
 )
 
@@ -20,6 +23,14 @@ const componentName = "comp/autodiscovery"
 
 // Component is the component type.
 type Component interface {
+	// Subscribe subscribes to ConfigChanges, with the given receiver options.
+	// The receiver first gets a ConfigChange for each currently scheduled
+	// config, followed by later changes.  The subscriber must call
+	// Unsubscribe when it no longer reads from the receiver.
+	Subscribe(opts ...subscriptions.Option) (subscriptions.Receiver[ConfigChange], error)
+
+	// Unsubscribe ends a subscription made with Subscribe.
+	Unsubscribe(subscriptions.Receiver[ConfigChange])
 }
 
 // Config defines config for a container or pod. XXX this is an
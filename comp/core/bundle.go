@@ -15,12 +15,15 @@ package core
 import (
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/config"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/flare"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/gui"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/health"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/internal"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/ipc/ipcclient"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/ipc/ipcserver"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/log"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/status"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/subscriptionmon"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/telemetry"
 	"go.uber.org/fx"
 )
 
@@ -36,15 +39,21 @@ var Bundle = fx.Module(
 
 	config.Module,
 	flare.Module,
+	gui.Module,
 	health.Module,
 	ipcclient.Module,
 	ipcserver.Module,
 	log.Module,
 	status.Module,
+	subscriptionmon.Module,
+	telemetry.Module,
 
 	// instantiate the ipcserver unconditionally, as nothing else actually depends
 	// on it (but it depends on a number of other things, such as flare and status)
 	fx.Invoke(func(ipcserver.Component) {}),
+
+	// likewise, nothing depends on subscriptionmon
+	fx.Invoke(func(subscriptionmon.Component) {}),
 )
 
 // MockBundle defines the mock fx options for this bundle.
@@ -55,9 +64,12 @@ var MockBundle = fx.Module(
 
 	config.MockModule,
 	flare.MockModule,
+	gui.Module,
 	health.Module,
-	ipcclient.Module,
+	ipcclient.MockModule,
 	ipcserver.MockModule,
 	log.MockModule,
 	status.Module,
+	subscriptionmon.Module,
+	telemetry.Module,
 )
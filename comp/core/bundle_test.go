@@ -10,11 +10,14 @@ import (
 
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/config"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/flare"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/gui"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/health"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/ipc/ipcclient"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/ipc/ipcserver"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/log"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/status"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/subscriptionmon"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/telemetry"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/fx"
 )
@@ -25,11 +28,14 @@ func TestBundleDependencies(t *testing.T) {
 		// automatically.
 		fx.Invoke(func(config.Component) {}),
 		fx.Invoke(func(flare.Component) {}),
+		fx.Invoke(func(gui.Component) {}),
 		fx.Invoke(func(health.Component) {}),
 		fx.Invoke(func(ipcclient.Component) {}),
 		fx.Invoke(func(ipcserver.Component) {}),
 		fx.Invoke(func(log.Component) {}),
 		fx.Invoke(func(status.Component) {}),
+		fx.Invoke(func(subscriptionmon.Component) {}),
+		fx.Invoke(func(telemetry.Component) {}),
 
 		fx.Supply(BundleParams{}),
 		Bundle))
@@ -41,7 +41,8 @@ type Component interface {
 type Mock interface {
 	Component
 
-	// TODO: Set..
+	// Set sets a config parameter value, overriding any previous value.
+	Set(key string, value interface{})
 }
 
 const componentName = "comp/core/config"
@@ -73,6 +73,11 @@ func (c *config) GetString(key string) string {
 	return c.viper.GetString(key)
 }
 
+// Set implements Mock#Set.
+func (c *config) Set(key string, value interface{}) {
+	c.viper.Set(key, value)
+}
+
 // WriteConfig implements Component#WriteConfig.
 func (c *config) WriteConfig(filename string) error {
 	return c.viper.SafeWriteConfigAs(filename)
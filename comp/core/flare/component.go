@@ -6,7 +6,8 @@
 // Package flare implements a component creates flares for submission to support.
 //
 // The data for this component is provided by other components, by providing a
-// flare.Registration instance.
+// flare.Registration instance.  Flares also include the metrics from the
+// telemetry component, if present, as `telemetry.txt`.
 //
 // This component registers itself with the ipcserver component, and supports either
 // generating a flare locally (CreateFlare) or calling the API to direct the running
@@ -22,6 +23,7 @@ package flare
 
 import (
 	"testing"
+	"time"
 
 	"go.uber.org/fx"
 )
@@ -30,6 +32,10 @@ import (
 
 const componentName = "comp/core/flare"
 
+// RemoteTimeout is the time the Agent allows for creating a flare requested
+// via the IPC API.  Clients requesting a flare should wait at least this long.
+const RemoteTimeout = 2 * time.Minute
+
 // Component is the component type.
 type Component interface {
 	// CreateFlare creates a new flare locally and returns the path to the
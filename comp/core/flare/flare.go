@@ -18,6 +18,7 @@ import (
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/config"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/ipc/ipcserver"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/log"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/telemetry"
 	"github.com/mholt/archiver"
 	"go.uber.org/fx"
 )
@@ -39,38 +40,54 @@ type dependencies struct {
 	Config        config.Component
 	Log           log.Component
 	Registrations []registration `group:"flare"`
+
+	// Telemetry, if present, is included in flares as telemetry.txt.
+	Telemetry telemetry.Component `optional:"true"`
 }
 
 func newFlare(deps dependencies) (Component, ipcserver.Route) {
 	f := &flare{
-		registrations: providedRegistrations(deps.Registrations),
+		registrations: providedRegistrations(deps.Registrations, deps.Telemetry),
 		log:           deps.Log,
 	}
 
-	return f, ipcserver.NewRoute("/agent/flare", f.ipcHandler)
+	return f, ipcserver.NewRoute("/agent/flare", "Create a flare",
+		[]string{http.MethodPost}, f.ipcHandler).WithTimeout(RemoteTimeout)
 }
 
 type mockDependencies struct {
 	fx.In
 
-	Registrations []registration `group:"flare"`
+	Registrations []registration      `group:"flare"`
+	Telemetry     telemetry.Component `optional:"true"`
 }
 
 func newMock(deps mockDependencies) Component {
 	// mock is just like the real thing, but doesn't use ipcserver or config.
 	return &flare{
-		registrations: providedRegistrations(deps.Registrations),
+		registrations: providedRegistrations(deps.Registrations, deps.Telemetry),
 	}
 }
 
-// providedRegistrations skips regsitrations with a nil callback.
-func providedRegistrations(registrations []registration) []registration {
-	provided := make([]registration, 0, len(registrations))
+// providedRegistrations skips regsitrations with a nil callback, and adds a
+// registration for telemetry.txt if telemetry is not nil.
+func providedRegistrations(registrations []registration, telemetry telemetry.Component) []registration {
+	provided := make([]registration, 0, len(registrations)+1)
 	for _, r := range registrations {
 		if r.callback != nil {
 			provided = append(provided, r)
 		}
 	}
+	if telemetry != nil {
+		provided = append(provided, FileRegistration("telemetry.txt", func() (string, error) {
+			var bldr strings.Builder
+			err := telemetry.WriteText(&bldr)
+			if err != nil {
+				return "", err
+			}
+			return bldr.String(), nil
+		}).Registration)
+	}
 	return provided
 }
 
@@ -14,6 +14,7 @@ import (
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/config"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/internal"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/log"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/telemetry"
 	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/comptest"
 	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/startup"
 	"github.com/mholt/archiver"
@@ -69,3 +70,19 @@ func TestMock(t *testing.T) {
 		require.Equal(t, "hello, world", content)
 	})
 }
+
+func TestTelemetry(t *testing.T) {
+	var flare Component
+	comptest.FxTest(t,
+		MockModule,
+		telemetry.Module,
+		fx.Invoke(func(tel telemetry.Component) {
+			tel.NewCounter("test", "things", nil, "Things").Inc()
+		}),
+		fx.Populate(&flare),
+	).WithRunningApp(func() {
+		content, err := flare.(Mock).GetFlareFile(t, "telemetry.txt")
+		require.NoError(t, err)
+		require.Contains(t, content, "test_things 1\n")
+	})
+}
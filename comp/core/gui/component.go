@@ -0,0 +1,39 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package gui implements a component serving a local status page for the
+// agent over the IPC API, at `/agent/gui`.
+//
+// The page includes the aggregate agent health, a table of component health,
+// the text rendering of all status sections, and a button to create a flare.
+// It refreshes itself periodically, every 5 seconds by default, or as given
+// by the `refresh` query parameter (in seconds).
+//
+// The page is built entirely from the comp/core/health and comp/core/status
+// components, so any component registering with those components is included
+// automatically.
+//
+// Like every IPC route, the page and its flare button require authentication.
+// A browser cannot send the IPC auth token, so `agent gui` prints a one-time
+// login URL, which sets a session cookie and redirects to the page.  The page
+// and the flare button then authenticate with that cookie.
+package gui
+
+import (
+	"go.uber.org/fx"
+)
+
+// team: agent-shared-components
+
+const componentName = "comp/core/gui"
+
+// Component is the component type.
+type Component interface{}
+
+// Module defines the fx options for this component.
+var Module = fx.Module(
+	componentName,
+	fx.Provide(newGUI),
+)
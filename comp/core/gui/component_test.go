@@ -0,0 +1,145 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package gui
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/config"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/flare"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/health"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/internal"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/ipc/ipcclient"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/ipc/ipcserver"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/log"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/status"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/telemetry"
+	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/comptest"
+	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/startup"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+)
+
+func TestPage(t *testing.T) {
+	var comp Component
+	healthReg := health.NewCriticalRegistration("comp/thing")
+	comptest.FxTest(t,
+		Module,
+		health.Module,
+		status.Module,
+		log.MockModule,
+		config.MockModule,
+		fx.Supply(internal.BundleParams{}),
+		fx.Supply(healthReg),
+		fx.Provide(func() status.Registration {
+			return status.NewRegistration("thing", "A thing", 1, func(context.Context) (any, error) {
+				return "<ok>", nil
+			}, "Thing: {{ . }}\n")
+		}),
+		fx.Populate(&comp),
+	).WithRunningApp(func() {
+		healthReg.Handle.SetStarting()
+		healthReg.Handle.SetReady()
+		healthReg.Handle.SetDegraded("slow")
+
+		w := httptest.NewRecorder()
+		comp.(*gui).ipcHandler(w, httptest.NewRequest("GET", "/agent/gui?refresh=30", nil))
+
+		require.Equal(t, 200, w.Code)
+		require.Equal(t, "text/html; charset=UTF-8", w.Header().Get("Content-Type"))
+		body := w.Body.String()
+		require.Contains(t, body, `Agent health: <span class="degraded">degraded</span>`)
+		require.Contains(t, body, `<tr><td>comp/thing</td><td>yes</td><td>ready</td><td class="degraded">degraded</td><td>slow</td></tr>`)
+		require.Contains(t, body, "Thing: &lt;ok&gt;")
+		require.Contains(t, body, " 30  * 1000")
+	})
+}
+
+func TestBrowser(t *testing.T) {
+	dir := t.TempDir()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	var client ipcclient.Component
+	comptest.FxTest(t,
+		Module,
+		health.Module,
+		status.Module,
+		flare.Module,
+		ipcserver.Module,
+		telemetry.Module,
+		ipcclient.Module,
+		log.MockModule,
+		config.MockModule,
+		fx.Supply(internal.BundleParams{AutoStart: startup.Always}),
+		fx.Invoke(func(c config.Component) {
+			c.(config.Mock).Set("cmd_port", port)
+			c.(config.Mock).Set("auth_token_file_path", filepath.Join(dir, "auth_token"))
+			c.(config.Mock).Set("ipc_cert_file_path", filepath.Join(dir, "ipc_cert.pem"))
+		}),
+		fx.Invoke(func(Component, ipcserver.Component) {}),
+		fx.Populate(&client),
+	).WithRunningApp(func() {
+		// a browser sends cookies, but not the auth token
+		jar, err := cookiejar.New(nil)
+		require.NoError(t, err)
+		browser := &http.Client{
+			Jar: jar,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			},
+		}
+		base := fmt.Sprintf("https://127.0.0.1:%d", port)
+
+		// without logging in, the page is not available
+		res, err := browser.Get(base + "/agent/gui")
+		require.NoError(t, err)
+		res.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+		// `agent gui` gets a login URL, which redirects to the page
+		var content map[string]string
+		require.NoError(t, client.PostJSON(context.Background(), "/agent/login/intent?next=/agent/gui", nil, &content))
+		loginURL := content["url"]
+		res, err = browser.Get(loginURL)
+		require.NoError(t, err)
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, "/agent/gui", res.Request.URL.Path)
+		require.Contains(t, string(body), "Create Flare")
+
+		// the flare button works
+		res, err = browser.Post(base+"/agent/flare", "", nil)
+		require.NoError(t, err)
+		var flareContent map[string]string
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&flareContent))
+		res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.NotEmpty(t, flareContent["filename"])
+		os.RemoveAll(filepath.Dir(flareContent["filename"]))
+
+		// the login URL can only be used once
+		res, err = (&http.Client{Transport: browser.Transport}).Get(loginURL)
+		require.NoError(t, err)
+		res.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	})
+}
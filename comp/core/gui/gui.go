@@ -0,0 +1,145 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package gui
+
+import (
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/health"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/ipc/ipcserver"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/status"
+	"go.uber.org/fx"
+)
+
+// defaultRefresh is the default page refresh interval, in seconds.
+const defaultRefresh = 5
+
+type gui struct {
+	// health is the health component
+	health health.Component
+
+	// status is the status component
+	status status.Component
+}
+
+type dependencies struct {
+	fx.In
+
+	Health health.Component
+	Status status.Component
+}
+
+func newGUI(deps dependencies) (Component, ipcserver.Route) {
+	g := &gui{
+		health: deps.Health,
+		status: deps.Status,
+	}
+	return g, ipcserver.NewRoute("/agent/gui", "Agent status page",
+		[]string{http.MethodGet}, g.ipcHandler)
+}
+
+// healthRow is a row in the health table.
+type healthRow struct {
+	Component string
+	health.ComponentHealth
+}
+
+// pageData is the data for pageTemplate.
+type pageData struct {
+	Refresh     int
+	AgentHealth health.Status
+	Health      []healthRow
+	Status      string
+}
+
+var pageTemplate = template.Must(template.New("gui").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Datadog Agent</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.8em; text-align: left; }
+.healthy { color: #2a7d2a; }
+.degraded { color: #b8860b; }
+.unhealthy { color: #c0392b; font-weight: bold; }
+pre { background: #f6f6f6; padding: 1em; }
+</style>
+</head>
+<body>
+<h1>Datadog Agent</h1>
+<p>Agent health: <span class="{{ .AgentHealth }}">{{ .AgentHealth }}</span></p>
+<p><button id="flare" onclick="createFlare()">Create Flare</button> <span id="flare-result"></span></p>
+
+<h2>Health</h2>
+<table>
+<tr><th>Component</th><th>Critical</th><th>State</th><th>Status</th><th>Message</th></tr>
+{{- range .Health }}
+<tr><td>{{ .Component }}</td><td>{{ if .Critical }}yes{{ else }}no{{ end }}</td><td>{{ .State }}</td><td class="{{ .Status }}">{{ .Status }}</td><td>{{ .Message }}</td></tr>
+{{- end }}
+</table>
+
+<h2>Status</h2>
+<pre>{{ .Status }}</pre>
+
+<script>
+var flaring = false;
+function createFlare() {
+  flaring = true;
+  document.getElementById("flare").disabled = true;
+  document.getElementById("flare-result").textContent = "Creating flare...";
+  fetch("/agent/flare", {method: "POST", credentials: "same-origin"})
+    .then(function(r) { return r.json(); })
+    .then(function(d) {
+      document.getElementById("flare-result").textContent =
+        d.error ? "Error: " + d.error : "Flare created at " + d.filename;
+    })
+    .catch(function(e) {
+      document.getElementById("flare-result").textContent = "Error: " + e;
+    })
+    .finally(function() {
+      flaring = false;
+      document.getElementById("flare").disabled = false;
+    });
+}
+setInterval(function() { if (!flaring) { location.reload(); } }, {{ .Refresh }} * 1000);
+</script>
+</body>
+</html>
+`))
+
+// ipcHandler serves the /agent/gui endpoint.
+func (g *gui) ipcHandler(w http.ResponseWriter, r *http.Request) {
+	data := pageData{Refresh: defaultRefresh}
+	if refresh, err := strconv.Atoi(r.URL.Query().Get("refresh")); err == nil && refresh > 0 {
+		data.Refresh = refresh
+	}
+
+	components := g.health.GetHealth()
+	data.AgentHealth = health.AggregateHealth(components)
+	for component, ch := range components {
+		data.Health = append(data.Health, healthRow{Component: component, ComponentHealth: ch})
+	}
+	sort.Slice(data.Health, func(i, j int) bool {
+		return data.Health[i].Component < data.Health[j].Component
+	})
+
+	text, err := g.status.Render(r.Context(), nil, status.TextFormat)
+	if err != nil {
+		text = err.Error()
+	}
+	data.Status = text
+
+	w.Header()["Content-Type"] = []string{"text/html; charset=UTF-8"}
+	err = pageTemplate.Execute(w, data)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+	}
+}
@@ -22,6 +22,27 @@
 // using the [actor model](https://en.wikipedia.org/wiki/Actor_model), where the
 // component is considered unhealthy if it is not polling for events frequently.
 //
+// A component's health is only reported while it is running.  Components mark
+// themselves as Starting when they start (often via Handle#HookLifecycle) and as
+// Ready once they are ready; actors using actor.MonitorLiveness do this
+// automatically.  Components that are never started, such as those in disabled
+// bundles, are not reported at all.  A component that remains Starting for
+// longer than `health_startup_timeout` seconds (default 60) is reported as
+// Unhealthy.  If `health_liveness_period` is set, it overrides the liveness
+// period of every actor.
+//
+// Each component has one of three statuses: Healthy, Degraded (working, but
+// with a problem that deserves attention, such as a growing backlog), or
+// Unhealthy (not working).  Registrations are either critical or non-critical.
+// The aggregate health of the agent is Unhealthy if any critical component is
+// Unhealthy, Degraded if any other component is not Healthy, and Healthy
+// otherwise.
+//
+// The health of all components is available over the IPC API, both at
+// `/agent/health` and via the streaming WatchHealth gRPC call in the ipcpb
+// package, which sends updates as health changes.  ToProto and FromProto
+// convert between the two representations.
+//
 // All of the component's methods can be called concurrently.
 package health
 
@@ -38,6 +59,10 @@ type Component interface {
 	// GetHealth gets a map containing the health of all components.  This map is a copy
 	// and will not be altered after return.
 	GetHealth() map[string]ComponentHealth
+
+	// GetAgentHealth gets the aggregate health of the agent, as computed by
+	// AggregateHealth.
+	GetAgentHealth() Status
 }
 
 // Registration is provided by other components to register themselves to
@@ -48,13 +73,24 @@ type Registration struct {
 	Handle *Handle `group:"health"`
 }
 
-// NewRegistration creates a new Registration instance for the named component.
+// NewRegistration creates a new Registration instance for the named, non-critical
+// component.  A non-critical component that is Unhealthy only degrades the
+// aggregate health of the agent.
 func NewRegistration(component string) Registration {
 	return Registration{
 		Handle: &Handle{component: component},
 	}
 }
 
+// NewCriticalRegistration creates a new Registration instance for the named,
+// critical component.  The agent is Unhealthy whenever a critical component is
+// Unhealthy.
+func NewCriticalRegistration(component string) Registration {
+	return Registration{
+		Handle: &Handle{component: component, critical: true},
+	}
+}
+
 // Module defines the fx options for this component.
 var Module = fx.Module(
 	componentName,
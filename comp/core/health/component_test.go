@@ -6,10 +6,15 @@
 package health
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/config"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/internal"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/ipc/ipcclient"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/ipc/ipcpb"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/ipc/ipcserver"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/log"
 	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/comptest"
 	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/startup"
@@ -28,10 +33,196 @@ func TestSimple(t *testing.T) {
 		fx.Supply(reg),
 		fx.Populate(&h),
 	).WithRunningApp(func() {
-		require.Equal(t, ComponentHealth{Healthy: true}, h.GetHealth()["comp/thing"])
+		reg.Handle.SetStarting()
+		reg.Handle.SetReady()
+		require.Equal(t, ComponentHealth{State: Ready, Status: Healthy}, h.GetHealth()["comp/thing"])
 		reg.Handle.SetUnhealthy("uhoh")
-		require.Equal(t, ComponentHealth{Healthy: false, Message: "uhoh"}, h.GetHealth()["comp/thing"])
+		require.Equal(t, ComponentHealth{State: Ready, Status: Unhealthy, Message: "uhoh"}, h.GetHealth()["comp/thing"])
+		reg.Handle.SetDegraded("meh")
+		require.Equal(t, ComponentHealth{State: Ready, Status: Degraded, Message: "meh"}, h.GetHealth()["comp/thing"])
 		reg.Handle.SetHealthy()
-		require.Equal(t, ComponentHealth{Healthy: true}, h.GetHealth()["comp/thing"])
+		require.Equal(t, ComponentHealth{State: Ready, Status: Healthy}, h.GetHealth()["comp/thing"])
+
+		// the restart count is kept when the component starts again
+		reg.Handle.RecordRestart()
+		reg.Handle.SetStarting()
+		reg.Handle.SetReady()
+		require.Equal(t, ComponentHealth{State: Ready, Status: Healthy, Restarts: 1}, h.GetHealth()["comp/thing"])
+	})
+}
+
+func TestAgentHealth(t *testing.T) {
+	var h Component
+	crit := NewCriticalRegistration("comp/critical")
+	other := NewRegistration("comp/other")
+	comptest.FxTest(t,
+		Module,
+		log.Module,
+		config.MockModule,
+		fx.Supply(internal.BundleParams{AutoStart: startup.Always}),
+		fx.Supply(crit),
+		fx.Supply(other),
+		fx.Populate(&h),
+	).WithRunningApp(func() {
+		for _, reg := range []Registration{crit, other} {
+			reg.Handle.SetStarting()
+			reg.Handle.SetReady()
+		}
+		require.Equal(t, Healthy, h.GetAgentHealth())
+		require.True(t, h.GetHealth()["comp/critical"].Critical)
+		require.False(t, h.GetHealth()["comp/other"].Critical)
+
+		other.Handle.SetUnhealthy("uhoh")
+		require.Equal(t, Degraded, h.GetAgentHealth())
+
+		crit.Handle.SetDegraded("meh")
+		require.Equal(t, Degraded, h.GetAgentHealth())
+
+		crit.Handle.SetUnhealthy("uhoh")
+		require.Equal(t, Unhealthy, h.GetAgentHealth())
+
+		crit.Handle.SetHealthy()
+		other.Handle.SetHealthy()
+		require.Equal(t, Healthy, h.GetAgentHealth())
+	})
+}
+
+func TestLifecycle(t *testing.T) {
+	var h Component
+	hooked := NewRegistration("comp/hooked")
+	unstarted := NewRegistration("comp/unstarted")
+	comptest.FxTest(t,
+		Module,
+		log.Module,
+		config.MockModule,
+		fx.Supply(internal.BundleParams{AutoStart: startup.Always}),
+		fx.Supply(hooked),
+		fx.Supply(unstarted),
+		fx.Invoke(func(lc fx.Lifecycle) { hooked.Handle.HookLifecycle(lc) }),
+		fx.Populate(&h),
+	).WithRunningApp(func() {
+		// unstarted is never reported
+		_, found := h.GetHealth()["comp/unstarted"]
+		require.False(t, found)
+
+		// hooked is starting until it is ready
+		require.Equal(t, Starting, h.GetHealth()["comp/hooked"].State)
+		require.Equal(t, Degraded, h.GetAgentHealth())
+
+		hooked.Handle.SetReady()
+		require.Equal(t, Ready, h.GetHealth()["comp/hooked"].State)
+		require.Equal(t, Healthy, h.GetAgentHealth())
+
+		hooked.Handle.SetStopped()
+		_, found = h.GetHealth()["comp/hooked"]
+		require.False(t, found)
+	})
+}
+
+func TestStartupTimeout(t *testing.T) {
+	var h Component
+	reg := NewCriticalRegistration("comp/slow")
+	comptest.FxTest(t,
+		Module,
+		log.Module,
+		config.MockModule,
+		fx.Supply(internal.BundleParams{AutoStart: startup.Always}),
+		fx.Supply(reg),
+		fx.Invoke(func(c config.Component) { c.(config.Mock).Set("health_startup_timeout", 1) }),
+		fx.Populate(&h),
+	).WithRunningApp(func() {
+		reg.Handle.SetStarting()
+		require.Equal(t, Healthy, h.GetHealth()["comp/slow"].Status)
+		require.Eventually(t, func() bool {
+			return h.GetHealth()["comp/slow"].Status == Unhealthy
+		}, 3*time.Second, 10*time.Millisecond)
+		require.Equal(t, Unhealthy, h.GetAgentHealth())
+
+		reg.Handle.SetReady()
+		require.Equal(t, Healthy, h.GetAgentHealth())
+	})
+}
+
+func TestStatusJSON(t *testing.T) {
+	for _, s := range []Status{Healthy, Degraded, Unhealthy} {
+		text, err := s.MarshalText()
+		require.NoError(t, err)
+		var got Status
+		require.NoError(t, got.UnmarshalText(text))
+		require.Equal(t, s, got)
+	}
+	var got Status
+	require.Error(t, got.UnmarshalText([]byte("bogus")))
+}
+
+func TestWatchHealth(t *testing.T) {
+	var client ipcclient.Component
+	reg := NewCriticalRegistration("comp/thing")
+	comptest.FxTest(t,
+		Module,
+		ipcserver.MockModule,
+		ipcclient.MockModule,
+		log.MockModule,
+		config.MockModule,
+		fx.Supply(internal.BundleParams{AutoStart: startup.Always}),
+		fx.Supply(reg),
+		fx.Invoke(func(Component) {}),
+		fx.Populate(&client),
+	).WithRunningApp(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		conn, err := client.GRPCConn(ctx)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		stream, err := ipcpb.NewHealthClient(conn).WatchHealth(ctx, &ipcpb.WatchHealthRequest{})
+		require.NoError(t, err)
+
+		recv := func() map[string]ComponentHealth {
+			update, err := stream.Recv()
+			require.NoError(t, err)
+			components := FromProto(update)
+			require.Equal(t, ipcpb.HealthStatus(AggregateHealth(components)), update.Agent)
+			return components
+		}
+
+		// the current health is sent first
+		require.Equal(t, map[string]ComponentHealth{}, recv())
+
+		reg.Handle.SetStarting()
+		require.Equal(t, map[string]ComponentHealth{
+			"comp/thing": {State: Starting, Status: Healthy, Critical: true},
+		}, recv())
+
+		reg.Handle.SetReady()
+		require.Equal(t, map[string]ComponentHealth{
+			"comp/thing": {State: Ready, Status: Healthy, Critical: true},
+		}, recv())
+
+		// setting the same health again is not a change
+		reg.Handle.SetHealthy()
+		reg.Handle.SetUnhealthy("uhoh")
+		require.Equal(t, map[string]ComponentHealth{
+			"comp/thing": {State: Ready, Status: Unhealthy, Critical: true, Message: "uhoh"},
+		}, recv())
+	})
+}
+
+func TestLivenessPeriod(t *testing.T) {
+	reg := NewRegistration("comp/thing")
+	unconfigured := NewRegistration("comp/other")
+	require.Equal(t, time.Second, unconfigured.Handle.LivenessPeriod(time.Second))
+
+	comptest.FxTest(t,
+		Module,
+		log.Module,
+		config.MockModule,
+		fx.Supply(internal.BundleParams{AutoStart: startup.Always}),
+		fx.Supply(reg),
+		fx.Invoke(func(c config.Component) { c.(config.Mock).Set("health_liveness_period", "5s") }),
+		fx.Invoke(func(Component) {}),
+	).WithRunningApp(func() {
+		require.Equal(t, 5*time.Second, reg.Handle.LivenessPeriod(time.Second))
 	})
 }
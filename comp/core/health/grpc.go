@@ -0,0 +1,83 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package health
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/ipc/ipcpb"
+)
+
+// watchRecheckInterval is the interval at which WatchHealth checks for changes
+// that are not signaled, such as a component exceeding the startup timeout.
+const watchRecheckInterval = time.Second
+
+// healthService implements ipcpb.HealthServer.
+type healthService struct {
+	ipcpb.UnimplementedHealthServer
+
+	h *health
+}
+
+// WatchHealth implements ipcpb.HealthServer#WatchHealth.
+func (s *healthService) WatchHealth(_ *ipcpb.WatchHealthRequest, stream ipcpb.Health_WatchHealthServer) error {
+	ticker := time.NewTicker(watchRecheckInterval)
+	defer ticker.Stop()
+
+	var last map[string]ComponentHealth
+	for {
+		components, changed := s.h.getHealth()
+		if last == nil || !reflect.DeepEqual(components, last) {
+			if err := stream.Send(ToProto(components)); err != nil {
+				return err
+			}
+			last = components
+		}
+
+		select {
+		case <-changed:
+		case <-ticker.C:
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+// ToProto converts the health of a set of components, as returned from
+// Component#GetHealth, to an ipcpb.HealthUpdate.
+func ToProto(components map[string]ComponentHealth) *ipcpb.HealthUpdate {
+	update := &ipcpb.HealthUpdate{
+		Components: make(map[string]*ipcpb.ComponentHealth, len(components)),
+		Agent:      ipcpb.HealthStatus(AggregateHealth(components)),
+	}
+	for name, ch := range components {
+		update.Components[name] = &ipcpb.ComponentHealth{
+			State:    ipcpb.ComponentState(ch.State),
+			Status:   ipcpb.HealthStatus(ch.Status),
+			Critical: ch.Critical,
+			Message:  ch.Message,
+			Restarts: uint32(ch.Restarts),
+		}
+	}
+	return update
+}
+
+// FromProto converts an ipcpb.HealthUpdate to the health of a set of
+// components, as returned from Component#GetHealth.
+func FromProto(update *ipcpb.HealthUpdate) map[string]ComponentHealth {
+	components := make(map[string]ComponentHealth, len(update.Components))
+	for name, ch := range update.Components {
+		components[name] = ComponentHealth{
+			State:    State(ch.State),
+			Status:   Status(ch.Status),
+			Critical: ch.Critical,
+			Message:  ch.Message,
+			Restarts: int(ch.Restarts),
+		}
+	}
+	return components
+}
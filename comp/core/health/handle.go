@@ -5,19 +5,81 @@
 
 package health
 
+import (
+	"context"
+	"time"
+
+	"go.uber.org/fx"
+)
+
 // Handle is the interface from other components to the health component.
 //
-// Handle methods must not be called until the calling component has
-// started.
+// A component's health is only reported once it has started, so each
+// component must mark itself as starting (SetStarting or HookLifecycle) and
+// later as ready (SetReady).  Actors using actor.MonitorLiveness do this
+// automatically.
+//
+// Handle methods other than HookLifecycle must not be called until the
+// calling component has started.
 type Handle struct {
 	// component is the name of the component being monitored.
 	component string
 
+	// critical is true if the component is critical to the agent's health.
+	critical bool
+
 	// health links to the comp/core/health component, once registration is
 	// complete.
 	health *health
 }
 
+// HookLifecycle connects this handle to the given fx.Lifecycle, marking the
+// component as Starting when the lifecycle starts and as Stopped when it stops.
+// Call this in the component's constructor, only if the component will start,
+// and before appending any of the component's own hooks.  The component must
+// still call SetReady once it is ready.
+func (reg *Handle) HookLifecycle(lc fx.Lifecycle) {
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			reg.SetStarting()
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			reg.SetStopped()
+			return nil
+		},
+	})
+}
+
+// SetStarting records this component as starting.  The component's health
+// is reported from this point on.  If the component does not call SetReady
+// within the configured startup timeout, it is reported as unhealthy.
+func (reg *Handle) SetStarting() {
+	// if comp/core/health hasn't been created, then there is nothing to do.
+	if reg.health != nil {
+		reg.health.setState(reg.component, Starting)
+	}
+}
+
+// SetReady records this component as ready.
+//
+// This method must not be called before the monitored component has started.
+func (reg *Handle) SetReady() {
+	// if comp/core/health hasn't been created, then there is nothing to do.
+	if reg.health != nil {
+		reg.health.setState(reg.component, Ready)
+	}
+}
+
+// SetStopped records this component as stopped.  The component's health is
+// no longer reported.
+func (reg *Handle) SetStopped() {
+	// if comp/core/health hasn't been created, then there is nothing to do.
+	if reg.health != nil {
+		reg.health.setState(reg.component, Stopped)
+	}
+}
+
 // SetUnhealthy records this component as being unhealthy, with the included message
 // summarizing the problem.
 //
@@ -25,7 +87,19 @@ type Handle struct {
 func (reg *Handle) SetUnhealthy(message string) {
 	// if comp/core/health hasn't been created, then there is nothing to do.
 	if reg.health != nil {
-		reg.health.setHealth(reg.component, false, message)
+		reg.health.setHealth(reg.component, Unhealthy, message)
+	}
+}
+
+// SetDegraded records this component as being degraded, with the included
+// message summarizing the problem.  A degraded component is still functioning,
+// but has a problem that deserves attention.
+//
+// This method must not be called before the monitored component has started.
+func (reg *Handle) SetDegraded(message string) {
+	// if comp/core/health hasn't been created, then there is nothing to do.
+	if reg.health != nil {
+		reg.health.setHealth(reg.component, Degraded, message)
 	}
 }
 
@@ -35,6 +109,76 @@ func (reg *Handle) SetUnhealthy(message string) {
 func (reg *Handle) SetHealthy() {
 	// if comp/core/health hasn't been created, then there is nothing to do.
 	if reg.health != nil {
-		reg.health.setHealth(reg.component, true, "")
+		reg.health.setHealth(reg.component, Healthy, "")
+	}
+}
+
+// RecordStack records a goroutine stack trace captured when this component was
+// found to be unhealthy, such as by a liveness monitor.  The most recent stack
+// for each component is included in subsequent flares, until it is cleared with
+// ClearStack.
+func (reg *Handle) RecordStack(stack string) {
+	// if comp/core/health hasn't been created, then there is nothing to do.
+	if reg.health != nil {
+		reg.health.recordStack(reg.component, stack)
+	}
+}
+
+// ClearStack clears any goroutine stack recorded with RecordStack, such as
+// when the component has recovered.
+func (reg *Handle) ClearStack() {
+	// if comp/core/health hasn't been created, then there is nothing to do.
+	if reg.health != nil {
+		reg.health.recordStack(reg.component, "")
+	}
+}
+
+// RecordRestart records that this component has been restarted after a
+// failure.  The count of restarts is reported with the component's health.
+//
+// This method must not be called before the monitored component has started.
+func (reg *Handle) RecordRestart() {
+	// if comp/core/health hasn't been created, then there is nothing to do.
+	if reg.health != nil {
+		reg.health.recordRestart(reg.component)
+	}
+}
+
+// LivenessPeriod returns the period for liveness checks of this component:
+// the configured `health_liveness_period`, if set, and otherwise the given
+// default.
+func (reg *Handle) LivenessPeriod(defaultPeriod time.Duration) time.Duration {
+	if reg.health != nil && reg.health.livenessPeriod > 0 {
+		return reg.health.livenessPeriod
+	}
+	return defaultPeriod
+}
+
+// RecordLiveness records the time this component took to respond to a
+// liveness check, as telemetry.
+func (reg *Handle) RecordLiveness(latency time.Duration) {
+	// if comp/core/health hasn't been created, then there is nothing to do.
+	if reg.health != nil {
+		reg.health.recordLiveness(reg.component, latency)
+	}
+}
+
+// RecordMissedTick records that this component did not respond to a liveness
+// check within the liveness period, as telemetry.
+func (reg *Handle) RecordMissedTick() {
+	// if comp/core/health hasn't been created, then there is nothing to do.
+	if reg.health != nil {
+		reg.health.recordMissedTick(reg.component)
+	}
+}
+
+// LivenessPanicAfter returns the number of consecutive missed liveness checks
+// after which a liveness monitor should panic, as configured by
+// `health_liveness_panic_after`.  This is intended for use when the agent runs
+// under a supervisor that will restart it.  A value of zero means never panic.
+func (reg *Handle) LivenessPanicAfter() int {
+	if reg.health != nil {
+		return reg.health.livenessPanicAfter
 	}
+	return 0
 }
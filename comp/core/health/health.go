@@ -8,14 +8,21 @@ package health
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/config"
 	flare "github.com/DataDog/dd-agent-comp-experiments/comp/core/flare"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/internal"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/ipc/ipcpb"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/ipc/ipcserver"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/log"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/telemetry"
 	"go.uber.org/fx"
 )
 
@@ -24,19 +31,62 @@ type health struct {
 	sync.Mutex
 
 	// components maps component package path to that component's current health status
-	components map[string]ComponentHealth
+	components map[string]*componentState
+
+	// startupTimeout is the time a component may remain Starting before it
+	// is considered unhealthy.
+	startupTimeout time.Duration
+
+	// livenessPanicAfter is the number of consecutive missed liveness checks
+	// after which liveness monitors should panic, or zero to never panic.
+	livenessPanicAfter int
+
+	// livenessPeriod is the configured liveness period, overriding that of
+	// each component, or zero if not configured.
+	livenessPeriod time.Duration
+
+	// livenessLatency and ticksMissed report liveness checks as telemetry,
+	// if the telemetry component is available.
+	livenessLatency telemetry.Histogram
+	ticksMissed     telemetry.Counter
+
+	// stacks maps component package path to the most recent goroutine stack
+	// recorded for that component.
+	stacks map[string]string
+
+	// changed is closed, and replaced, whenever a component's health changes.
+	changed chan struct{}
 
 	// log supports logging about changes in health status
 	log log.Component
 }
 
+// componentState is the health component's record of a single component.
+type componentState struct {
+	ComponentHealth
+
+	// startedAt is the time at which the component entered the Starting state.
+	startedAt time.Time
+}
+
+// defaultStartupTimeout is used when `health_startup_timeout` is not set.
+const defaultStartupTimeout = 60 * time.Second
+
+// livenessLatencyBuckets are the histogram buckets for liveness latency, in
+// seconds.  Healthy loops respond within milliseconds.
+var livenessLatencyBuckets = []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1, 5}
+
 type dependencies struct {
 	fx.In
 
 	Lc     fx.Lifecycle
 	Params internal.BundleParams
+	Config config.Component
 	Log    log.Component
 
+	// Telemetry, if present, records liveness checks.
+	Telemetry telemetry.Component `optional:"true"`
+
 	Handles []*Handle `group:"health"`
 }
 
@@ -44,41 +94,104 @@ type provides struct {
 	fx.Out
 
 	Component
-	FlareReg flare.Registration
-	IPCRoute ipcserver.Route
+	FlareReg      flare.Registration
+	StackFlareReg flare.Registration
+	IPCRoute      ipcserver.Route
+	GRPCService   ipcserver.GRPCService
 }
 
 func newHealth(deps dependencies) provides {
 	h := &health{
-		components: make(map[string]ComponentHealth),
-		log:        deps.Log,
+		components:     make(map[string]*componentState),
+		startupTimeout: time.Duration(deps.Config.GetInt("health_startup_timeout")) * time.Second,
+		stacks:         make(map[string]string),
+		changed:        make(chan struct{}),
+		log:            deps.Log,
+
+		livenessPanicAfter: deps.Config.GetInt("health_liveness_panic_after"),
+	}
+	if h.startupTimeout <= 0 {
+		h.startupTimeout = defaultStartupTimeout
+	}
+	if period := deps.Config.GetString("health_liveness_period"); period != "" {
+		d, err := time.ParseDuration(period)
+		if err != nil || d <= 0 {
+			deps.Log.Error(fmt.Sprintf("invalid health_liveness_period %q; using each component's default", period))
+		} else {
+			h.livenessPeriod = d
+		}
+	}
+	if deps.Telemetry != nil {
+		h.livenessLatency = deps.Telemetry.NewHistogram("health", "liveness_latency_seconds", []string{"component"},
+			"Time taken by actor loops to respond to liveness checks", livenessLatencyBuckets)
+		h.ticksMissed = deps.Telemetry.NewCounter("health", "liveness_ticks_missed", []string{"component"},
+			"Liveness checks to which actor loops did not respond in time")
 	}
 
-	// provide each registration with a pointer to the new component, and
-	// default to a healthy status. The Handles will update the component
-	// as health status changes.
+	// provide each registration with a pointer to the new component.  The
+	// component is not reported until it starts, and the Handles will update
+	// the component as its state and health status change.
 	for _, handle := range deps.Handles {
 		handle.health = h
-		h.components[handle.component] = ComponentHealth{Healthy: true}
+		h.components[handle.component] = &componentState{
+			ComponentHealth: ComponentHealth{
+				State:    NotStarted,
+				Status:   Healthy,
+				Critical: handle.critical,
+			},
+		}
 	}
 
 	return provides{
-		Component: h,
-		FlareReg:  flare.FileRegistration("health.json", h.flareFile),
-		IPCRoute:  ipcserver.NewRoute("/agent/health", h.ipcHandler),
+		Component:     h,
+		FlareReg:      flare.FileRegistration("health.json", h.flareFile),
+		StackFlareReg: flare.CallbackRegistration(h.stackFlareFiles),
+		IPCRoute:      ipcserver.NewRoute("/agent/health", "Health of all components", []string{http.MethodGet}, h.ipcHandler),
+		GRPCService:   ipcserver.NewGRPCService(&ipcpb.Health_ServiceDesc, &healthService{h: h}),
 	}
 }
 
 // GetHealth implements Component#GetHealth.
 func (h *health) GetHealth() map[string]ComponentHealth {
+	components, _ := h.getHealth()
+	return components
+}
+
+// getHealth gets the health of all components, as for GetHealth, along with a
+// channel that will be closed at the next change.  Note that a component
+// exceeding the startup timeout does not close the channel.
+func (h *health) getHealth() (map[string]ComponentHealth, <-chan struct{}) {
 	h.Lock()
 	defer h.Unlock()
 
+	now := time.Now()
 	rv := map[string]ComponentHealth{}
-	for k, v := range h.components {
-		rv[k] = v
+	for k, cs := range h.components {
+		// only components that are running are included
+		if cs.State != Starting && cs.State != Ready {
+			continue
+		}
+
+		ch := cs.ComponentHealth
+		if ch.State == Starting && now.Sub(cs.startedAt) > h.startupTimeout {
+			ch.Status = Unhealthy
+			ch.Message = fmt.Sprintf("did not become ready within %s", h.startupTimeout)
+		}
+		rv[k] = ch
 	}
-	return rv
+	return rv, h.changed
+}
+
+// notifyChanged notifies watchers of a change.  It must be called with the
+// mutex held.
+func (h *health) notifyChanged() {
+	close(h.changed)
+	h.changed = make(chan struct{})
+}
+
+// GetAgentHealth implements Component#GetAgentHealth.
+func (h *health) GetAgentHealth() Status {
+	return AggregateHealth(h.GetHealth())
 }
 
 // ipcHandler serves the /agent/health endpoint
@@ -94,28 +207,118 @@ func (h *health) flareFile() (string, error) {
 	return bldr.String(), nil
 }
 
+// stackFlareFiles writes the recorded goroutine stacks, one file per component,
+// into the liveness-stacks directory of the flare.
+func (h *health) stackFlareFiles(flareDir string) error {
+	h.Lock()
+	defer h.Unlock()
+
+	if len(h.stacks) == 0 {
+		return nil
+	}
+
+	dir := filepath.Join(flareDir, "liveness-stacks")
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return err
+	}
+
+	for component, stack := range h.stacks {
+		filename := strings.ReplaceAll(component, "/", "-") + ".txt"
+		err = ioutil.WriteFile(filepath.Join(dir, filename), []byte(stack), 0o600)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordStack records a goroutine stack for a specific component, or clears
+// it if stack is empty.  It is called from the Handle type.
+func (h *health) recordStack(component string, stack string) {
+	h.Lock()
+	defer h.Unlock()
+
+	if _, found := h.components[component]; !found {
+		return
+	}
+	if stack == "" {
+		delete(h.stacks, component)
+	} else {
+		h.stacks[component] = stack
+	}
+}
+
+// setState sets the lifecycle state of a specific component.  It is called
+// from the Handle type.
+func (h *health) setState(component string, state State) {
+	h.Lock()
+	defer h.Unlock()
+
+	if cs, found := h.components[component]; found {
+		if state == cs.State {
+			return
+		}
+		h.log.Debug(fmt.Sprintf("Component %s is now %s", component, state))
+		if state == Starting {
+			// a (re)starting component begins with a clean slate
+			cs.startedAt = time.Now()
+			cs.Status = Healthy
+			cs.Message = ""
+		}
+		cs.State = state
+		h.notifyChanged()
+	}
+}
+
 // setHealth sets the health of a specific component.  It is called from the
-// XxxRegistration types.
-func (h *health) setHealth(component string, healthy bool, message string) {
+// Handle type.
+func (h *health) setHealth(component string, status Status, message string) {
 	h.Lock()
 	defer h.Unlock()
 
-	if ch, found := h.components[component]; found {
+	if cs, found := h.components[component]; found {
 		// XXX: we will probably want to do more than just log
-		if healthy && !ch.Healthy {
-			h.log.Debug(fmt.Sprintf("Component %s is now healthy", component))
-		}
-		if !healthy && ch.Healthy {
-			h.log.Debug(fmt.Sprintf("Component %s is now unhealthy: %s", component, message))
+		if status != cs.Status {
+			if status == Healthy {
+				h.log.Debug(fmt.Sprintf("Component %s is now healthy", component))
+			} else {
+				h.log.Debug(fmt.Sprintf("Component %s is now %s: %s", component, status, message))
+			}
 		}
-		h.components[component] = ComponentHealth{
-			Healthy: healthy,
-			Message: message,
+		if status != cs.Status || message != cs.Message {
+			cs.Status = status
+			cs.Message = message
+			h.notifyChanged()
 		}
 	}
 }
 
-type ComponentHealth struct {
-	Healthy bool
-	Message string
+// recordRestart counts a restart of a specific component.  It is called from
+// the Handle type.
+func (h *health) recordRestart(component string) {
+	h.Lock()
+	defer h.Unlock()
+
+	if cs, found := h.components[component]; found {
+		cs.Restarts++
+		h.log.Debug(fmt.Sprintf("Component %s restarted (%d restarts)", component, cs.Restarts))
+		h.notifyChanged()
+	}
+}
+
+// recordLiveness records the latency of a liveness check for a specific
+// component.  It is called from the Handle type.
+func (h *health) recordLiveness(component string, latency time.Duration) {
+	if h.livenessLatency != nil {
+		h.livenessLatency.Observe(latency.Seconds(), component)
+	}
+}
+
+// recordMissedTick records a missed liveness check for a specific component.
+// It is called from the Handle type.
+func (h *health) recordMissedTick(component string) {
+	if h.ticksMissed != nil {
+		h.ticksMissed.Inc(component)
+	}
 }
@@ -0,0 +1,133 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package health
+
+import "fmt"
+
+// Status is the health status of a component, or of the agent as a whole.
+// Larger values are more severe.
+type Status int
+
+const (
+	// Healthy indicates that the component is operating normally.  This is
+	// the zero value.
+	Healthy Status = iota
+
+	// Degraded indicates that the component is operating, but has a problem
+	// that deserves attention.
+	Degraded
+
+	// Unhealthy indicates that the component has failed.
+	Unhealthy
+)
+
+var statusNames = []string{"healthy", "degraded", "unhealthy"}
+
+// String implements fmt.Stringer.
+func (s Status) String() string {
+	if s < 0 || int(s) >= len(statusNames) {
+		return fmt.Sprintf("Status(%d)", int(s))
+	}
+	return statusNames[s]
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *Status) UnmarshalText(text []byte) error {
+	for i, name := range statusNames {
+		if name == string(text) {
+			*s = Status(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown health status %q", string(text))
+}
+
+// State is the lifecycle state of a component.
+type State int
+
+const (
+	// NotStarted indicates that the component has not started, such as
+	// because it is disabled.  This is the zero value.
+	NotStarted State = iota
+
+	// Starting indicates that the component has started, but is not yet
+	// ready.
+	Starting
+
+	// Ready indicates that the component has started and is ready.
+	Ready
+
+	// Stopped indicates that the component has stopped.
+	Stopped
+)
+
+var stateNames = []string{"not-started", "starting", "ready", "stopped"}
+
+// String implements fmt.Stringer.
+func (s State) String() string {
+	if s < 0 || int(s) >= len(stateNames) {
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+	return stateNames[s]
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *State) UnmarshalText(text []byte) error {
+	for i, name := range stateNames {
+		if name == string(text) {
+			*s = State(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown component state %q", string(text))
+}
+
+// ComponentHealth is the health of a single component.
+type ComponentHealth struct {
+	// State is the component's lifecycle state.  Only components that are
+	// Starting or Ready are included in Component#GetHealth.
+	State State
+
+	// Status is the component's current health status.
+	Status Status
+
+	// Critical is true if the component was registered as critical.
+	Critical bool
+
+	// Message summarizes the problem, if Status is not Healthy.
+	Message string
+
+	// Restarts is the number of times the component has been restarted after
+	// a failure, such as by an actor with RestartOnFailure.
+	Restarts int
+}
+
+// AggregateHealth computes the aggregate health of the agent from the health
+// of its components.  The result is Unhealthy if any critical component is
+// Unhealthy, Degraded if any other component is not Healthy or is not yet
+// Ready, and Healthy otherwise.
+func AggregateHealth(components map[string]ComponentHealth) Status {
+	agg := Healthy
+	for _, ch := range components {
+		switch {
+		case ch.Status == Unhealthy && ch.Critical:
+			return Unhealthy
+		case ch.Status != Healthy || ch.State != Ready:
+			agg = Degraded
+		}
+	}
+	return agg
+}
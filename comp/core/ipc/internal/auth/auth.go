@@ -0,0 +1,235 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package auth manages the authentication material shared by the IPC server
+// and client: a bearer token and a self-signed TLS certificate, each stored
+// in a file readable only by the agent's user.
+//
+// The server creates these files on first start, if they do not already
+// exist.  The client only reads them.
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/config"
+)
+
+const (
+	// defaultTokenPath is used when `auth_token_file_path` is not set.
+	defaultTokenPath = "/etc/datadog-agent/auth_token"
+
+	// defaultCertPath is used when `ipc_cert_file_path` is not set.
+	defaultCertPath = "/etc/datadog-agent/ipc_cert.pem"
+)
+
+// TokenPath returns the path of the auth token file.
+func TokenPath(config config.Component) string {
+	if path := config.GetString("auth_token_file_path"); path != "" {
+		return path
+	}
+	return defaultTokenPath
+}
+
+// CertPath returns the path of the file containing the IPC certificate and its
+// private key.
+func CertPath(config config.Component) string {
+	if path := config.GetString("ipc_cert_file_path"); path != "" {
+		return path
+	}
+	return defaultCertPath
+}
+
+// FetchOrCreateToken reads the auth token from the given path, first creating
+// it with a new random token if it does not exist.
+func FetchOrCreateToken(path string) (string, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return "", fmt.Errorf("Could not generate auth token: %w", err)
+		}
+		if err := writeFile(path, []byte(hex.EncodeToString(buf))); err != nil {
+			return "", fmt.Errorf("Could not write auth token: %w", err)
+		}
+	}
+	return ReadToken(path)
+}
+
+// ReadToken reads the auth token from the given path.
+func ReadToken(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("Could not read auth token: %w", err)
+	}
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return "", fmt.Errorf("Auth token file %s is empty", path)
+	}
+	return token, nil
+}
+
+// FetchOrCreateCert reads the certificate and key from the given path, first
+// creating a new self-signed certificate for localhost if it does not exist.
+func FetchOrCreateCert(path string) (tls.Certificate, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		content, err := generateCert()
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("Could not generate IPC certificate: %w", err)
+		}
+		if err := writeFile(path, content); err != nil {
+			return tls.Certificate{}, fmt.Errorf("Could not write IPC certificate: %w", err)
+		}
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("Could not read IPC certificate: %w", err)
+	}
+	return tls.X509KeyPair(content, content)
+}
+
+// ReadCertPool reads the certificate from the given path, returning a pool
+// containing only that certificate.  Clients use this to trust only the
+// agent's certificate.
+func ReadCertPool(path string) (*x509.CertPool, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read IPC certificate: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(content) {
+		return nil, fmt.Errorf("No certificate found in %s", path)
+	}
+	return pool, nil
+}
+
+// generateCert generates a self-signed certificate valid for localhost,
+// returning it and its private key in PEM format.
+func generateCert() ([]byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Datadog, Inc."}, CommonName: "datadog-agent IPC"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		DNSNames:              []string{"localhost"},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	content := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	content = append(content, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})...)
+	return content, nil
+}
+
+// writeFile writes a file readable only by the current user, replacing it
+// atomically so that readers never see partial content.
+func writeFile(path string, content []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	// CreateTemp uses mode 0600, but be explicit as this is security-sensitive
+	if err := f.Chmod(0o600); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// SetBearer sets the Authorization header on the request.
+func SetBearer(req *http.Request, token string) {
+	req.Header.Set("Authorization", "Bearer "+token)
+}
+
+// CheckBearer determines whether the given Authorization header value carries
+// the given token as a bearer token.
+func CheckBearer(header, token string) bool {
+	provided := strings.TrimPrefix(header, "Bearer ")
+	return provided != header && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
+
+// RequireToken wraps the given handler, rejecting requests that do not carry
+// the given token as a bearer token with a 401 response and a JSON body of the
+// form {"error": <message>}.
+func RequireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !CheckBearer(r.Header.Get("Authorization"), token) {
+			w.Header()["Content-Type"] = []string{"application/json; charset=UTF-8"}
+			w.Header()["WWW-Authenticate"] = []string{"Bearer"}
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid or missing auth token"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// BearerCredentials implements credentials.PerRPCCredentials, sending the
+// token as a bearer token with each gRPC call.
+type BearerCredentials string
+
+// GetRequestMetadata implements credentials.PerRPCCredentials#GetRequestMetadata.
+func (c BearerCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(c)}, nil
+}
+
+// RequireTransportSecurity implements
+// credentials.PerRPCCredentials#RequireTransportSecurity.
+func (c BearerCredentials) RequireTransportSecurity() bool {
+	return true
+}
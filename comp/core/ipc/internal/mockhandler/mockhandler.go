@@ -0,0 +1,38 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package mockhandler connects the ipcserver and ipcclient mocks, so that
+// requests and gRPC calls made with the mock client are served in-process by
+// the routes and services registered with the mock server.
+package mockhandler
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+)
+
+// Handler is provided by the ipcserver mock, and serves all registered routes.
+type Handler struct {
+	http.Handler
+
+	// DialGRPC connects to the mock's in-process gRPC server.
+	DialGRPC func(ctx context.Context) (net.Conn, error)
+}
+
+// RoundTrip implements http.RoundTripper, serving the request in-process.
+func (h Handler) RoundTrip(req *http.Request) (*http.Response, error) {
+	// make the client request look like a server request, as handlers expect
+	req = req.Clone(req.Context())
+	if req.Body == nil {
+		req.Body = http.NoBody
+	}
+	req.RequestURI = req.URL.RequestURI()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Result(), nil
+}
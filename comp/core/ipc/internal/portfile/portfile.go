@@ -0,0 +1,52 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package portfile manages the runtime file in which the IPC server records
+// the TCP port it chose, when `cmd_port` is 0, so that clients can find it.
+package portfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/config"
+)
+
+// defaultRunPath is used when `run_path` is not set.
+const defaultRunPath = "/opt/datadog-agent/run"
+
+// Path returns the path of the port file, `ipc_port` in `run_path`.
+func Path(config config.Component) string {
+	runPath := config.GetString("run_path")
+	if runPath == "" {
+		runPath = defaultRunPath
+	}
+	return filepath.Join(runPath, "ipc_port")
+}
+
+// Write records the given port in the port file.
+func Write(path string, port int) error {
+	err := os.WriteFile(path, []byte(strconv.Itoa(port)+"\n"), 0o644)
+	if err != nil {
+		return fmt.Errorf("Could not write IPC port file: %w", err)
+	}
+	return nil
+}
+
+// Read reads the port from the port file.
+func Read(path string) (int, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("Could not read IPC port file (is the Agent running?): %w", err)
+	}
+	port, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return 0, fmt.Errorf("Invalid IPC port file %s: %w", path, err)
+	}
+	return port, nil
+}
@@ -4,10 +4,39 @@
 // Copyright 2016-present Datadog, Inc.
 
 // Package ipcclient implements a component to access the IPC server remotely.
+//
+// The client authenticates with the auth token created by the ipcserver
+// component, and trusts only the server's self-signed certificate.  Both are
+// read from the files named in the configuration, so the client must run as a
+// user able to read those files.  If `cmd_port` is 0, the client reads the port
+// chosen by the server from its port file.
+//
+// If `ipc_socket_path` is set, the client instead connects to the server's unix
+// socket at that path, where access is controlled by the socket's file
+// permissions.
+//
+// Requests time out after `ipc_client_timeout` seconds (default 30), unless the
+// caller's context already has a deadline, which then applies instead.  Callers
+// making requests that the Agent may take longer to handle, such as creating a
+// flare, should set such a deadline.  While the Agent appears not to be
+// running, such as when it is still starting up, requests are retried with
+// backoff for up to `ipc_client_retry_timeout` seconds (default 3).  Errors
+// distinguish an Agent that is not running (ErrAgentNotRunning) from one that
+// responded with an error (*AgentError).  Servers report errors with a JSON
+// body of the form {"error": <message>}.
+//
+// The mock version of this component sends all requests and gRPC calls,
+// in-process, to the routes and services registered with the mock ipcserver
+// component.
 package ipcclient
 
 import (
+	"context"
+	"errors"
+	"fmt"
+
 	"go.uber.org/fx"
+	"google.golang.org/grpc"
 )
 
 // team: agent-shared-components
@@ -16,11 +45,59 @@ const componentName = "comp/core/ipc/ipcclient"
 
 // Component is the component type.
 type Component interface {
-	// GetJSON gets the body of the server response from the given path, as JSON
-	GetJSON(path string, v any) error
+	// GetJSON gets the body of the server response from the given path, and
+	// decodes it as JSON into v.
+	GetJSON(ctx context.Context, path string, v any) error
+
+	// PostJSON posts the given body, encoded as JSON, to the given path, and
+	// decodes the server response as JSON into v.  If body is nil, the
+	// request has no body.  If v is nil, the response is ignored.
+	PostJSON(ctx context.Context, path string, body any, v any) error
+
+	// Stream gets the server response from the given path, calling cb for
+	// each non-empty line of the response as it arrives.  The request is not
+	// subject to the client timeout, and continues until the response ends,
+	// ctx is done, or cb returns an error.
+	Stream(ctx context.Context, path string, cb func(line []byte) error) error
+
+	// GRPCConn creates a connection to the server's gRPC services, for use
+	// with the generated clients in the ipcpb package.  The caller must close
+	// the connection when finished with it.
+	//
+	// The connection is established lazily, so calls fail with
+	// codes.Unavailable while the Agent cannot be reached.  Use the
+	// grpc.WaitForReady call option to wait for the Agent to start instead.
+	GRPCConn(ctx context.Context) (*grpc.ClientConn, error)
+}
+
+// ErrAgentNotRunning is wrapped by errors returned when the Agent could not be
+// contacted because it is not running.  Callers can check for this with
+// errors.Is, and perform the operation locally instead, if possible.
+var ErrAgentNotRunning = errors.New("Agent is not running")
+
+// AgentError is returned when the Agent responds to a request with an error.
+type AgentError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+
+	// Message is the error message from the Agent, or the HTTP status if the
+	// Agent did not provide one.
+	Message string
+}
+
+// Error implements error#Error.
+func (e *AgentError) Error() string {
+	return fmt.Sprintf("Error from Agent: %s", e.Message)
 }
 
 var Module = fx.Module(
 	componentName,
 	fx.Provide(newClient),
 )
+
+// MockModule defines the fx options for the mock component.  It requires the
+// mock ipcserver component, and sends requests to its routes in-process.
+var MockModule = fx.Module(
+	componentName,
+	fx.Provide(newMock),
+)
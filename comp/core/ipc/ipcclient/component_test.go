@@ -0,0 +1,230 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package ipcclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/config"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/internal"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/ipc/ipcserver"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/log"
+	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/comptest"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+)
+
+func testRoutes() fx.Option {
+	return fx.Options(
+		fx.Provide(func() ipcserver.Route {
+			return ipcserver.NewRoute("/echo", "Echo", []string{http.MethodPost}, func(w http.ResponseWriter, r *http.Request) {
+				var body map[string]string
+				json.NewDecoder(r.Body).Decode(&body)
+				json.NewEncoder(w).Encode(map[string]any{"received": body})
+			})
+		}),
+		fx.Provide(func() ipcserver.Route {
+			return ipcserver.NewRoute("/broken", "Broken", []string{http.MethodGet}, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(map[string]string{"error": "uhoh"})
+			})
+		}),
+		fx.Provide(func() ipcserver.Route {
+			return ipcserver.NewRoute("/lines", "Lines", []string{http.MethodGet}, func(w http.ResponseWriter, r *http.Request) {
+				for i := 0; i < 3; i++ {
+					fmt.Fprintf(w, "{\"n\": %d}\n\n", i)
+				}
+			}).WithTimeout(0)
+		}),
+	)
+}
+
+func TestPostJSON(t *testing.T) {
+	var client Component
+	comptest.FxTest(t,
+		MockModule,
+		ipcserver.MockModule,
+		log.MockModule,
+		testRoutes(),
+		fx.Populate(&client),
+	).WithRunningApp(func() {
+		var content map[string]any
+		require.NoError(t, client.PostJSON(context.Background(), "/echo", map[string]string{"fruit": "apple"}, &content))
+		require.Equal(t, map[string]any{"received": map[string]any{"fruit": "apple"}}, content)
+
+		// responses can be ignored
+		require.NoError(t, client.PostJSON(context.Background(), "/echo", nil, nil))
+	})
+}
+
+func TestAgentError(t *testing.T) {
+	var client Component
+	comptest.FxTest(t,
+		MockModule,
+		ipcserver.MockModule,
+		log.MockModule,
+		testRoutes(),
+		fx.Populate(&client),
+	).WithRunningApp(func() {
+		var agentErr *AgentError
+
+		err := client.GetJSON(context.Background(), "/broken", nil)
+		require.ErrorAs(t, err, &agentErr)
+		require.Equal(t, &AgentError{StatusCode: http.StatusInternalServerError, Message: "uhoh"}, agentErr)
+		require.Equal(t, "Error from Agent: uhoh", err.Error())
+		require.NotErrorIs(t, err, ErrAgentNotRunning)
+
+		// without an error body, the status is used
+		err = client.GetJSON(context.Background(), "/nope", nil)
+		require.ErrorAs(t, err, &agentErr)
+		require.Equal(t, http.StatusNotFound, agentErr.StatusCode)
+		require.Equal(t, "404 Not Found", agentErr.Message)
+	})
+}
+
+func TestStream(t *testing.T) {
+	var client Component
+	comptest.FxTest(t,
+		MockModule,
+		ipcserver.MockModule,
+		log.MockModule,
+		testRoutes(),
+		fx.Populate(&client),
+	).WithRunningApp(func() {
+		var lines []string
+		err := client.Stream(context.Background(), "/lines", func(line []byte) error {
+			lines = append(lines, string(line))
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{`{"n": 0}`, `{"n": 1}`, `{"n": 2}`}, lines)
+
+		// an error from the callback stops the stream
+		stop := errors.New("stop")
+		lines = nil
+		err = client.Stream(context.Background(), "/lines", func(line []byte) error {
+			lines = append(lines, string(line))
+			return stop
+		})
+		require.ErrorIs(t, err, stop)
+		require.Equal(t, []string{`{"n": 0}`}, lines)
+	})
+}
+
+func TestNotRunning(t *testing.T) {
+	dir := t.TempDir()
+
+	var client Component
+	comptest.FxTest(t,
+		Module,
+		config.MockModule,
+		fx.Supply(internal.BundleParams{}),
+		fx.Invoke(func(c config.Component) {
+			c.(config.Mock).Set("auth_token_file_path", filepath.Join(dir, "auth_token"))
+			c.(config.Mock).Set("ipc_cert_file_path", filepath.Join(dir, "ipc_cert.pem"))
+			c.(config.Mock).Set("ipc_client_retry_timeout", 1)
+		}),
+		fx.Populate(&client),
+	).WithRunningApp(func() {
+		// the Agent has never run, so there is no token file
+		start := time.Now()
+		err := client.GetJSON(context.Background(), "/agent/health", nil)
+		require.ErrorIs(t, err, ErrAgentNotRunning)
+		require.GreaterOrEqual(t, time.Since(start), 500*time.Millisecond, "should have retried")
+
+		// a cancelled context stops retrying
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		start = time.Now()
+		err = client.GetJSON(ctx, "/agent/health", nil)
+		require.ErrorIs(t, err, ErrAgentNotRunning)
+		require.Less(t, time.Since(start), 500*time.Millisecond)
+	})
+}
+
+func TestRetryUntilRunning(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "agent.sock")
+
+	var client Component
+	comptest.FxTest(t,
+		Module,
+		config.MockModule,
+		fx.Supply(internal.BundleParams{}),
+		fx.Invoke(func(c config.Component) {
+			c.(config.Mock).Set("ipc_socket_path", socketPath)
+			c.(config.Mock).Set("ipc_client_retry_timeout", 5)
+		}),
+		fx.Populate(&client),
+	).WithRunningApp(func() {
+		server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"ok": true}`))
+		})}
+		defer server.Close()
+
+		// start serving only after the client has begun retrying
+		go func() {
+			time.Sleep(200 * time.Millisecond)
+			listener, err := net.Listen("unix", socketPath)
+			if err != nil {
+				return
+			}
+			server.Serve(listener)
+		}()
+
+		var content map[string]bool
+		require.NoError(t, client.GetJSON(context.Background(), "/test", &content))
+		require.Equal(t, map[string]bool{"ok": true}, content)
+	})
+}
+
+func TestCallerDeadline(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "agent.sock")
+
+	var client Component
+	comptest.FxTest(t,
+		Module,
+		config.MockModule,
+		fx.Supply(internal.BundleParams{}),
+		fx.Invoke(func(c config.Component) {
+			c.(config.Mock).Set("ipc_socket_path", socketPath)
+			c.(config.Mock).Set("ipc_client_timeout", 1)
+		}),
+		fx.Populate(&client),
+	).WithRunningApp(func() {
+		// a flare handler that takes longer than the client timeout
+		listener, err := net.Listen("unix", socketPath)
+		require.NoError(t, err)
+		server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-time.After(1500 * time.Millisecond):
+				w.Write([]byte(`{"filename": "flare.zip"}`))
+			case <-r.Context().Done():
+			}
+		})}
+		go server.Serve(listener)
+		defer server.Close()
+
+		// without a deadline, the client timeout applies
+		err = client.PostJSON(context.Background(), "/agent/flare", nil, nil)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.NotErrorIs(t, err, ErrAgentNotRunning)
+
+		// a longer deadline set by the caller is not shortened
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		var content map[string]string
+		require.NoError(t, client.PostJSON(ctx, "/agent/flare", nil, &content))
+		require.Equal(t, map[string]string{"filename": "flare.zip"}, content)
+	})
+}
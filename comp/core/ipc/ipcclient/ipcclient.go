@@ -6,18 +6,73 @@
 package ipcclient
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"net"
 	"net/http"
+	"os"
+	"syscall"
+	"time"
 
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/config"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/ipc/internal/auth"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/ipc/internal/mockhandler"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/ipc/internal/portfile"
 	"go.uber.org/fx"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const (
+	// defaultTimeout is used when `ipc_client_timeout` is not set.
+	defaultTimeout = 30 * time.Second
+
+	// defaultRetryTimeout is used when `ipc_client_retry_timeout` is not set.
+	defaultRetryTimeout = 3 * time.Second
+
+	// initialBackoff and maxBackoff bound the delay between retries.
+	initialBackoff = 100 * time.Millisecond
+	maxBackoff     = time.Second
 )
 
 type client struct {
-	// port is the port on which the server is running.
+	// port is the port on which the server is running, or zero if it should
+	// be read from the port file.
 	port int
+
+	// portFilePath is the path of the port file
+	portFilePath string
+
+	// socketPath is the path of the server's unix socket, or empty if the
+	// client should use TCP
+	socketPath string
+
+	// tokenPath is the path of the auth token file
+	tokenPath string
+
+	// certPath is the path of the server's TLS certificate file
+	certPath string
+
+	// timeout is the timeout for non-streaming requests
+	timeout time.Duration
+
+	// retryTimeout is the maximum time to retry requests while the Agent is
+	// not running
+	retryTimeout time.Duration
+
+	// mockTransport, if not nil, serves all requests in-process
+	mockTransport http.RoundTripper
+
+	// mockDialGRPC, if not nil, connects to an in-process gRPC server
+	mockDialGRPC func(context.Context) (net.Conn, error)
 }
 
 type dependencies struct {
@@ -28,38 +83,339 @@ type dependencies struct {
 
 func newClient(deps dependencies) Component {
 	a := &client{
-		port: deps.Config.GetInt("cmd_port"),
+		port:         deps.Config.GetInt("cmd_port"),
+		portFilePath: portfile.Path(deps.Config),
+		socketPath:   deps.Config.GetString("ipc_socket_path"),
+		tokenPath:    auth.TokenPath(deps.Config),
+		certPath:     auth.CertPath(deps.Config),
+		timeout:      time.Duration(deps.Config.GetInt("ipc_client_timeout")) * time.Second,
+		retryTimeout: time.Duration(deps.Config.GetInt("ipc_client_retry_timeout")) * time.Second,
+	}
+	if a.timeout <= 0 {
+		a.timeout = defaultTimeout
+	}
+	if a.retryTimeout <= 0 {
+		a.retryTimeout = defaultRetryTimeout
 	}
 	return a
 }
 
+type mockDependencies struct {
+	fx.In
+
+	Handler mockhandler.Handler
+}
+
+func newMock(deps mockDependencies) Component {
+	return &client{
+		timeout:       defaultTimeout,
+		mockTransport: deps.Handler,
+		mockDialGRPC:  deps.Handler.DialGRPC,
+	}
+}
+
 // GetJSON implements Component#GetJSON.
-func (a *client) GetJSON(path string, v any) error {
-	url := fmt.Sprintf("http://127.0.0.1:%d%s", a.port, path)
-	res, err := http.Get(url)
+func (a *client) GetJSON(ctx context.Context, path string, v any) error {
+	ctx, cancel := a.withTimeout(ctx)
+	defer cancel()
+
+	res, err := a.do(ctx, http.MethodGet, path, nil)
 	if err != nil {
-		return fmt.Errorf("Error contacting Agent: %s", err)
+		return err
 	}
+	defer res.Body.Close()
 
-	if res.Body != nil {
-		defer res.Body.Close()
+	return decodeResponse(res, v)
+}
+
+// PostJSON implements Component#PostJSON.
+func (a *client) PostJSON(ctx context.Context, path string, body any, v any) error {
+	var content []byte
+	if body != nil {
+		var err error
+		content, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := a.withTimeout(ctx)
+	defer cancel()
+
+	res, err := a.do(ctx, http.MethodPost, path, content)
+	if err != nil {
+		return err
 	}
+	defer res.Body.Close()
 
-	if res.StatusCode != 200 {
-		return fmt.Errorf("Error contacting Agent: %s", res.Status)
+	return decodeResponse(res, v)
+}
+
+// withTimeout applies the client timeout to ctx, unless the caller has already
+// set a deadline on it, which then applies instead, even if it is later.
+func (a *client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, found := ctx.Deadline(); found {
+		return context.WithCancel(ctx)
 	}
+	return context.WithTimeout(ctx, a.timeout)
+}
 
-	body, err := ioutil.ReadAll(res.Body)
+// Stream implements Component#Stream.
+func (a *client) Stream(ctx context.Context, path string, cb func(line []byte) error) error {
+	res, err := a.do(ctx, http.MethodGet, path, nil)
 	if err != nil {
 		return err
 	}
+	defer res.Body.Close()
 
-	err = json.Unmarshal(body, v)
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		if err := cb(line); err != nil {
+			return err
+		}
+	}
+
+	// a cancelled context interrupts reading the body; report that instead
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return scanner.Err()
+}
+
+// GRPCConn implements Component#GRPCConn.
+func (a *client) GRPCConn(ctx context.Context) (*grpc.ClientConn, error) {
+	var target string
+	var opts []grpc.DialOption
 
+	switch {
+	case a.mockDialGRPC != nil:
+		target = "passthrough:///agent"
+		opts = append(opts,
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				return a.mockDialGRPC(ctx)
+			}))
+
+	case a.socketPath != "":
+		// as for HTTP requests, the socket uses neither TLS nor the token
+		target = "passthrough:///agent"
+		opts = append(opts,
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", a.socketPath)
+			}))
+
+	default:
+		token, pool, port, err := a.readTCPFiles()
+		if err != nil {
+			return nil, err
+		}
+		target = fmt.Sprintf("127.0.0.1:%d", port)
+		opts = append(opts,
+			grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+				RootCAs:    pool,
+				MinVersion: tls.VersionTLS12,
+			})),
+			grpc.WithPerRPCCredentials(auth.BearerCredentials(token)))
+	}
+
+	return grpc.DialContext(ctx, target, opts...)
+}
+
+// decodeResponse decodes a successful response's body as JSON into v, if v is
+// not nil.
+func decodeResponse(res *http.Response, v any) error {
+	body, err := io.ReadAll(res.Body)
 	if err != nil {
-		return fmt.Errorf("Error decoding Agent response: %s", err)
+		return fmt.Errorf("Error reading Agent response: %w", err)
 	}
 
+	if v == nil {
+		return nil
+	}
+
+	err = json.Unmarshal(body, v)
+	if err != nil {
+		return fmt.Errorf("Error decoding Agent response: %w", err)
+	}
 	return nil
+}
+
+// do performs a request, retrying with backoff for up to retryTimeout while
+// the Agent is not running.  On success, the response has a 2xx status and
+// the caller must close its body.  A non-2xx response is returned as an
+// *AgentError.
+func (a *client) do(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	deadline := time.Now().Add(a.retryTimeout)
+	backoff := initialBackoff
+	for {
+		res, err := a.doOnce(ctx, method, path, body)
+		if err == nil || !errors.Is(err, ErrAgentNotRunning) || time.Now().Add(backoff).After(deadline) {
+			return res, err
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, err
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// doOnce performs a single request.
+func (a *client) doOnce(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	transport, baseURL, token, err := a.connection()
+	if err != nil {
+		return nil, err
+	}
+
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, baseURL+path, bodyReader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		auth.SetBearer(req, token)
+	}
+
+	res, err := (&http.Client{Transport: transport}).Do(req)
+	if err != nil {
+		if isNotRunning(err) {
+			return nil, fmt.Errorf("%w: %s", ErrAgentNotRunning, err)
+		}
+		return nil, fmt.Errorf("Error contacting Agent: %w", err)
+	}
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		defer res.Body.Close()
+		return nil, decodeError(res)
+	}
+
+	return res, nil
+}
+
+// decodeError creates an *AgentError from an error response, using the error
+// message from the Agent if it provided one in a body of the form
+// {"error": <message>}.
+func decodeError(res *http.Response) error {
+	agentErr := &AgentError{StatusCode: res.StatusCode, Message: res.Status}
+
+	body, err := io.ReadAll(res.Body)
+	if err == nil {
+		var errContent struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &errContent) == nil && errContent.Error != "" {
+			agentErr.Message = errContent.Error
+		}
+	}
+
+	return agentErr
+}
+
+// isNotRunning determines whether an error connecting to the Agent indicates
+// that it is not running.
+func isNotRunning(err error) bool {
+	// ECONNREFUSED for TCP, and ENOENT for a socket that does not exist
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, os.ErrNotExist)
+}
+
+// connection returns the transport to use, the base URL for requests, and the
+// auth token (empty if not required), using the unix socket if configured and
+// TCP otherwise.
+func (a *client) connection() (http.RoundTripper, string, string, error) {
+	if a.mockTransport != nil {
+		return a.mockTransport, "http://agent", "", nil
+	}
+	if a.socketPath != "" {
+		return a.socketConnection()
+	}
+	return a.tcpConnection()
+}
+
+// socketConnection returns a transport that dials the server's unix socket.
+// Access to the socket is controlled by its file permissions, so no token is
+// required.
+func (a *client) socketConnection() (http.RoundTripper, string, string, error) {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", a.socketPath)
+		},
+		// the transport is not reused, so do not keep idle connections
+		DisableKeepAlives: true,
+	}
+
+	// the host is ignored when dialing, but must be valid
+	return transport, "http://agent", "", nil
+}
+
+// tcpConnection returns a transport that trusts only the server's
+// certificate, along with the auth token.
+func (a *client) tcpConnection() (http.RoundTripper, string, string, error) {
+	token, pool, port, err := a.readTCPFiles()
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	transport := &http.Transport{
+		TLSClientConfig: &tls.Config{
+			RootCAs:    pool,
+			MinVersion: tls.VersionTLS12,
+		},
+		// the transport is not reused, so do not keep idle connections
+		DisableKeepAlives: true,
+	}
+	return transport, fmt.Sprintf("https://127.0.0.1:%d", port), token, nil
+}
+
+// readTCPFiles reads the auth token, the server's certificate, and (if
+// necessary) the port.  These are read for every connection, as the server
+// creates them when it starts, which may be after this component is created.
+// If they do not exist, the Agent has never run, so the error wraps
+// ErrAgentNotRunning.
+func (a *client) readTCPFiles() (string, *x509.CertPool, int, error) {
+	wrap := func(err error) error {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w: %s", ErrAgentNotRunning, err)
+		}
+		return fmt.Errorf("Error contacting Agent: %w", err)
+	}
+
+	token, err := auth.ReadToken(a.tokenPath)
+	if err != nil {
+		return "", nil, 0, wrap(err)
+	}
+
+	pool, err := auth.ReadCertPool(a.certPath)
+	if err != nil {
+		return "", nil, 0, wrap(err)
+	}
+
+	port := a.port
+	if port == 0 {
+		port, err = portfile.Read(a.portFilePath)
+		if err != nil {
+			return "", nil, 0, wrap(err)
+		}
+	}
 
+	return token, pool, port, nil
 }
@@ -0,0 +1,145 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: health.proto
+
+package ipcpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Health_WatchHealth_FullMethodName = "/datadog.agent.ipc.Health/WatchHealth"
+)
+
+// HealthClient is the client API for Health service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type HealthClient interface {
+	// WatchHealth sends the health of all running components, and then sends
+	// it again each time it changes, until the call is cancelled.
+	WatchHealth(ctx context.Context, in *WatchHealthRequest, opts ...grpc.CallOption) (Health_WatchHealthClient, error)
+}
+
+type healthClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewHealthClient(cc grpc.ClientConnInterface) HealthClient {
+	return &healthClient{cc}
+}
+
+func (c *healthClient) WatchHealth(ctx context.Context, in *WatchHealthRequest, opts ...grpc.CallOption) (Health_WatchHealthClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Health_ServiceDesc.Streams[0], Health_WatchHealth_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &healthWatchHealthClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Health_WatchHealthClient interface {
+	Recv() (*HealthUpdate, error)
+	grpc.ClientStream
+}
+
+type healthWatchHealthClient struct {
+	grpc.ClientStream
+}
+
+func (x *healthWatchHealthClient) Recv() (*HealthUpdate, error) {
+	m := new(HealthUpdate)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// HealthServer is the server API for Health service.
+// All implementations must embed UnimplementedHealthServer
+// for forward compatibility
+type HealthServer interface {
+	// WatchHealth sends the health of all running components, and then sends
+	// it again each time it changes, until the call is cancelled.
+	WatchHealth(*WatchHealthRequest, Health_WatchHealthServer) error
+	mustEmbedUnimplementedHealthServer()
+}
+
+// UnimplementedHealthServer must be embedded to have forward compatible implementations.
+type UnimplementedHealthServer struct {
+}
+
+func (UnimplementedHealthServer) WatchHealth(*WatchHealthRequest, Health_WatchHealthServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchHealth not implemented")
+}
+func (UnimplementedHealthServer) mustEmbedUnimplementedHealthServer() {}
+
+// UnsafeHealthServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to HealthServer will
+// result in compilation errors.
+type UnsafeHealthServer interface {
+	mustEmbedUnimplementedHealthServer()
+}
+
+func RegisterHealthServer(s grpc.ServiceRegistrar, srv HealthServer) {
+	s.RegisterService(&Health_ServiceDesc, srv)
+}
+
+func _Health_WatchHealth_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchHealthRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(HealthServer).WatchHealth(m, &healthWatchHealthServer{stream})
+}
+
+type Health_WatchHealthServer interface {
+	Send(*HealthUpdate) error
+	grpc.ServerStream
+}
+
+type healthWatchHealthServer struct {
+	grpc.ServerStream
+}
+
+func (x *healthWatchHealthServer) Send(m *HealthUpdate) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Health_ServiceDesc is the grpc.ServiceDesc for Health service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Health_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "datadog.agent.ipc.Health",
+	HandlerType: (*HealthServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchHealth",
+			Handler:       _Health_WatchHealth_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "health.proto",
+}
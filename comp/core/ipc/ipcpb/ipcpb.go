@@ -0,0 +1,14 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package ipcpb contains the protocol buffer definitions of the gRPC services
+// in the IPC API, along with the generated server interfaces and clients.
+//
+// Components implement a service by providing an ipcserver.GRPCService, and
+// clients call it over a connection from the ipcclient component's GRPCConn
+// method.
+package ipcpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative health.proto
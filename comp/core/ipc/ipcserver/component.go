@@ -9,17 +9,66 @@
 // The handlers in the HTTP server are supplied by other components, by providing a
 // ipcserver.Route from their constructor.
 //
-// The Mock version of this component allows registration but does not actually
-// start a server.
+// The Mock version of this component collects all registered routes and gRPC
+// services but does not actually start a server.  Instead, tests can issue
+// requests against those routes in-process with its Get and Post methods, and
+// the mock version of the ipcclient component sends its requests and gRPC
+// calls to the same routes and services, so that CLI commands can be tested
+// end-to-end.  The mock does not require the auth token.
 //
-// XXX In a real agent, this would support TLS and gRPC and Auth and timeouts
-// and stuff; see cmd/agent/api.
+// The server is served over TLS, using a self-signed certificate stored with its
+// private key at `ipc_cert_file_path` (default /etc/datadog-agent/ipc_cert.pem).
+// Every request must carry the token stored at `auth_token_file_path` (default
+// /etc/datadog-agent/auth_token) as a bearer token, or it is rejected with a 401
+// response.  Both files are created, readable only by the agent's user, when the
+// server first starts.  The ipcclient component reads these files to
+// authenticate itself and to verify the server's certificate.
+//
+// Browsers cannot send the auth token, so a client with the token can POST to
+// `/agent/login/intent?next=<path>` to get a one-time login URL.  Opening that
+// URL in a browser, within five minutes, sets an HttpOnly session cookie and
+// redirects to the given path.  The session cookie is accepted in place of the
+// auth token for one hour, after which the browser must log in again.
+//
+// The TCP listener is bound to 127.0.0.1:`cmd_port` during startup, and any
+// failure to start, such as a port conflict, fails startup.  If `cmd_port` is
+// 0, the server chooses a free port and records it in the file `ipc_port` in
+// `run_path` (default /opt/datadog-agent/run), where the ipcclient component
+// will find it.
+//
+// Each route declares the HTTP methods it accepts, a description, whether it
+// requires the auth token, and a timeout.  Every request passes through a chain
+// of middleware that logs the request, records it in telemetry, recovers
+// panics in the handler as a 500 response, checks the auth token, and applies
+// the route's timeout.  Requests, errors (5xx responses), and latency are
+// recorded per route path as `ipcserver_requests`, `ipcserver_request_errors`,
+// and `ipcserver_request_latency_seconds`.  The `/agent/routes` route lists all
+// registered routes along with their request and error counts and mean
+// latency, read from those metrics.  The server also serves all telemetry, in
+// the Prometheus text format, at `/metrics`.
+//
+// The same routes can also be served over a unix socket at `ipc_socket_path`,
+// if set.  The socket is accessible only to the agent's user, and since access
+// is controlled by its file permissions, requests over the socket use neither
+// TLS nor the auth token.  Both transports run simultaneously unless
+// `ipc_tcp_disabled` is true, easing migration of clients to the socket.
+//
+// Components can also provide gRPC services, by providing an
+// ipcserver.GRPCService from their constructor.  These are served on the same
+// listeners as the routes, distinguished by their content type, and so use the
+// same TLS certificate and auth token (sent as a bearer token in the call
+// metadata).  Service definitions are in the ipcpb package.  Calls are logged
+// and panics are recovered, but calls have no timeout.
 package ipcserver
 
 import (
+	"io"
 	"net/http"
+	"net/http/httptest"
+	"time"
 
 	"go.uber.org/fx"
+	"google.golang.org/grpc"
 )
 
 // team: agent-shared-components
@@ -34,7 +83,14 @@ type Component interface {
 type Mock interface {
 	Component
 
-	// TODO: Get(path) ..
+	// Get issues a GET request for the given path against the registered
+	// routes, in-process, and returns the recorded response.
+	Get(path string) *httptest.ResponseRecorder
+
+	// Post issues a POST request for the given path, with the given body,
+	// against the registered routes, in-process, and returns the recorded
+	// response.
+	Post(path string, body io.Reader) *httptest.ResponseRecorder
 }
 
 // Route is provided by other components in order to indicate routes that
@@ -45,10 +101,59 @@ type Route struct {
 	Route route `group:"ipcserver"`
 }
 
-// NewRoute creates a new Route instance for the named component.
-func NewRoute(path string, handler http.HandlerFunc) Route {
+// NewRoute creates a new Route serving `handler` at `path`, for the given HTTP
+// methods.  Requests with other methods receive a 405 response.  The
+// description is a short, human-readable description of the route, shown in
+// the `/agent/routes` index.
+//
+// The route requires the auth token and has a timeout of 30 seconds; use
+// WithoutAuth and WithTimeout to change this.
+func NewRoute(path, description string, methods []string, handler http.HandlerFunc) Route {
 	return Route{
-		Route: route{path, handler},
+		Route: route{
+			path:        path,
+			description: description,
+			methods:     methods,
+			auth:        true,
+			timeout:     defaultRouteTimeout,
+			handler:     handler,
+		},
+	}
+}
+
+// WithTimeout returns a copy of the Route with the given timeout, after which
+// the request's context is cancelled and the client receives a 503 response.
+// A zero timeout disables the timeout, as is necessary for streaming
+// responses.
+func (r Route) WithTimeout(timeout time.Duration) Route {
+	r.Route.timeout = timeout
+	return r
+}
+
+// WithoutAuth returns a copy of the Route that does not require the auth
+// token.
+func (r Route) WithoutAuth() Route {
+	r.Route.auth = false
+	return r
+}
+
+// GRPCService is provided by other components in order to indicate gRPC
+// services that should be served via the IPC API.
+type GRPCService struct {
+	fx.Out
+
+	Service grpcService `group:"ipcserver_grpc"`
+}
+
+// NewGRPCService creates a new GRPCService serving the service described by
+// desc, as generated in the ipcpb package, with the given implementation of
+// the service's server interface.
+func NewGRPCService(desc *grpc.ServiceDesc, impl any) GRPCService {
+	return GRPCService{
+		Service: grpcService{
+			desc: desc,
+			impl: impl,
+		},
 	}
 }
 
@@ -0,0 +1,600 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package ipcserver
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/config"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/internal"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/ipc/internal/auth"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/ipc/ipcclient"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/log"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/telemetry"
+	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/comptest"
+	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/startup"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// freePort finds a port on which nothing is listening.
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func TestAuth(t *testing.T) {
+	dir := t.TempDir()
+	tokenPath := filepath.Join(dir, "auth_token")
+	certPath := filepath.Join(dir, "ipc_cert.pem")
+	port := freePort(t)
+
+	var client ipcclient.Component
+	comptest.FxTest(t,
+		Module,
+		telemetry.Module,
+		ipcclient.Module,
+		config.MockModule,
+		log.MockModule,
+		fx.Supply(internal.BundleParams{AutoStart: startup.Always}),
+		fx.Invoke(func(c config.Component) {
+			c.(config.Mock).Set("cmd_port", port)
+			c.(config.Mock).Set("auth_token_file_path", tokenPath)
+			c.(config.Mock).Set("ipc_cert_file_path", certPath)
+		}),
+		fx.Provide(func() Route {
+			return NewRoute("/test", "Test route", []string{http.MethodGet}, func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"ok": true}`))
+			})
+		}),
+		fx.Invoke(func(Component) {}),
+		fx.Populate(&client),
+	).WithRunningApp(func() {
+		// the token and certificate are created, readable only by this user
+		for _, path := range []string{tokenPath, certPath} {
+			info, err := os.Stat(path)
+			require.NoError(t, err)
+			require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+		}
+
+		// the client authenticates and verifies the certificate
+		var content map[string]bool
+		require.NoError(t, client.GetJSON(context.Background(), "/test", &content))
+		require.Equal(t, map[string]bool{"ok": true}, content)
+
+		// requests without a valid token are rejected
+		httpClient := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}}
+		url := fmt.Sprintf("https://127.0.0.1:%d/test", port)
+		for _, header := range []string{"", "Bearer wrong", "Basic abc"} {
+			req, err := http.NewRequest("GET", url, nil)
+			require.NoError(t, err)
+			if header != "" {
+				req.Header.Set("Authorization", header)
+			}
+			res, err := httpClient.Do(req)
+			require.NoError(t, err)
+			res.Body.Close()
+			require.Equal(t, http.StatusUnauthorized, res.StatusCode, "with header %q", header)
+		}
+
+		// plain HTTP is not served
+		res, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/test", port))
+		if err == nil {
+			res.Body.Close()
+			require.Equal(t, http.StatusBadRequest, res.StatusCode)
+		}
+	})
+}
+
+func TestClientRejectsOtherCert(t *testing.T) {
+	dir := t.TempDir()
+	port := freePort(t)
+
+	var client ipcclient.Component
+	comptest.FxTest(t,
+		Module,
+		telemetry.Module,
+		ipcclient.Module,
+		config.MockModule,
+		log.MockModule,
+		fx.Supply(internal.BundleParams{AutoStart: startup.Always}),
+		fx.Invoke(func(c config.Component) {
+			c.(config.Mock).Set("cmd_port", port)
+			c.(config.Mock).Set("auth_token_file_path", filepath.Join(dir, "auth_token"))
+			c.(config.Mock).Set("ipc_cert_file_path", filepath.Join(dir, "ipc_cert.pem"))
+		}),
+		fx.Invoke(func(Component) {}),
+		fx.Populate(&client),
+	).WithRunningApp(func() {
+		// replace the certificate the client reads with a different one
+		// after the server has started
+		require.NoError(t, os.Remove(filepath.Join(dir, "ipc_cert.pem")))
+		_, err := auth.FetchOrCreateCert(filepath.Join(dir, "ipc_cert.pem"))
+		require.NoError(t, err)
+
+		var content map[string]any
+		err = client.GetJSON(context.Background(), "/test", &content)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "certificate")
+	})
+}
+
+func TestSocket(t *testing.T) {
+	dir := t.TempDir()
+	socketPath := filepath.Join(dir, "agent.sock")
+	tokenPath := filepath.Join(dir, "auth_token")
+	certPath := filepath.Join(dir, "ipc_cert.pem")
+	port := freePort(t)
+
+	var client ipcclient.Component
+	comptest.FxTest(t,
+		Module,
+		telemetry.Module,
+		ipcclient.Module,
+		config.MockModule,
+		log.MockModule,
+		fx.Supply(internal.BundleParams{AutoStart: startup.Always}),
+		fx.Invoke(func(c config.Component) {
+			c.(config.Mock).Set("cmd_port", port)
+			c.(config.Mock).Set("ipc_socket_path", socketPath)
+			c.(config.Mock).Set("auth_token_file_path", tokenPath)
+			c.(config.Mock).Set("ipc_cert_file_path", certPath)
+		}),
+		fx.Provide(func() Route {
+			return NewRoute("/test", "Test route", []string{http.MethodGet}, func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"ok": true}`))
+			})
+		}),
+		fx.Invoke(func(Component) {}),
+		fx.Populate(&client),
+	).WithRunningApp(func() {
+		// the socket is accessible only to this user
+		info, err := os.Stat(socketPath)
+		require.NoError(t, err)
+		require.Equal(t, os.ModeSocket, info.Mode().Type())
+		require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+		// the private directory in which it was created is removed
+		matches, err := filepath.Glob(filepath.Join(dir, ".ipc-socket-*"))
+		require.NoError(t, err)
+		require.Empty(t, matches)
+
+		// the client uses the socket
+		var content map[string]bool
+		require.NoError(t, client.GetJSON(context.Background(), "/test", &content))
+		require.Equal(t, map[string]bool{"ok": true}, content)
+
+		// the TCP transport runs at the same time
+		token, err := os.ReadFile(tokenPath)
+		require.NoError(t, err)
+		httpClient := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}}
+		req, err := http.NewRequest("GET", fmt.Sprintf("https://127.0.0.1:%d/test", port), nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+string(token))
+		res, err := httpClient.Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+	})
+
+	// the socket is removed when the server stops
+	_, err := os.Stat(socketPath)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestPortConflict(t *testing.T) {
+	dir := t.TempDir()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	app := comptest.FxTest(t,
+		Module,
+		telemetry.Module,
+		config.MockModule,
+		log.MockModule,
+		fx.Supply(internal.BundleParams{AutoStart: startup.Always}),
+		fx.Invoke(func(c config.Component) {
+			c.(config.Mock).Set("cmd_port", l.Addr().(*net.TCPAddr).Port)
+			c.(config.Mock).Set("ipc_socket_path", filepath.Join(dir, "agent.sock"))
+			c.(config.Mock).Set("auth_token_file_path", filepath.Join(dir, "auth_token"))
+			c.(config.Mock).Set("ipc_cert_file_path", filepath.Join(dir, "ipc_cert.pem"))
+		}),
+		fx.Invoke(func(Component) {}),
+	)
+
+	err = app.Start(context.Background())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Could not start IPC server")
+
+	// the socket transport, which did start, was stopped
+	_, err = os.Stat(filepath.Join(dir, "agent.sock"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestEphemeralPort(t *testing.T) {
+	dir := t.TempDir()
+
+	var client ipcclient.Component
+	comptest.FxTest(t,
+		Module,
+		telemetry.Module,
+		ipcclient.Module,
+		config.MockModule,
+		log.MockModule,
+		fx.Supply(internal.BundleParams{AutoStart: startup.Always}),
+		fx.Invoke(func(c config.Component) {
+			c.(config.Mock).Set("cmd_port", 0)
+			c.(config.Mock).Set("run_path", dir)
+			c.(config.Mock).Set("auth_token_file_path", filepath.Join(dir, "auth_token"))
+			c.(config.Mock).Set("ipc_cert_file_path", filepath.Join(dir, "ipc_cert.pem"))
+		}),
+		fx.Provide(func() Route {
+			return NewRoute("/test", "Test route", []string{http.MethodGet}, func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"ok": true}`))
+			})
+		}),
+		fx.Invoke(func(Component) {}),
+		fx.Populate(&client),
+	).WithRunningApp(func() {
+		// the client finds the chosen port in the port file
+		var content map[string]bool
+		require.NoError(t, client.GetJSON(context.Background(), "/test", &content))
+		require.Equal(t, map[string]bool{"ok": true}, content)
+	})
+
+	// the port file is removed when the server stops
+	_, err := os.Stat(filepath.Join(dir, "ipc_port"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestMiddleware(t *testing.T) {
+	dir := t.TempDir()
+	port := freePort(t)
+
+	comptest.FxTest(t,
+		Module,
+		telemetry.Module,
+		config.MockModule,
+		log.MockModule,
+		fx.Supply(internal.BundleParams{AutoStart: startup.Always}),
+		fx.Invoke(func(c config.Component) {
+			c.(config.Mock).Set("cmd_port", port)
+			c.(config.Mock).Set("auth_token_file_path", filepath.Join(dir, "auth_token"))
+			c.(config.Mock).Set("ipc_cert_file_path", filepath.Join(dir, "ipc_cert.pem"))
+		}),
+		fx.Provide(func() Route {
+			return NewRoute("/post", "Post only", []string{http.MethodPost}, func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{}`))
+			})
+		}),
+		fx.Provide(func() Route {
+			return NewRoute("/panic", "Panics", []string{http.MethodGet}, func(w http.ResponseWriter, r *http.Request) {
+				panic("uhoh")
+			})
+		}),
+		fx.Provide(func() Route {
+			return NewRoute("/slow", "Times out", []string{http.MethodGet}, func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+			}).WithTimeout(10 * time.Millisecond)
+		}),
+		fx.Provide(func() Route {
+			return NewRoute("/open", "No auth", []string{http.MethodGet}, func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{}`))
+			}).WithoutAuth()
+		}),
+		fx.Invoke(func(Component) {}),
+	).WithRunningApp(func() {
+		token, err := os.ReadFile(filepath.Join(dir, "auth_token"))
+		require.NoError(t, err)
+		httpClient := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}}
+		do := func(method, path string, withToken bool) *http.Response {
+			req, err := http.NewRequest(method, fmt.Sprintf("https://127.0.0.1:%d%s", port, path), nil)
+			require.NoError(t, err)
+			if withToken {
+				req.Header.Set("Authorization", "Bearer "+string(token))
+			}
+			res, err := httpClient.Do(req)
+			require.NoError(t, err)
+			t.Cleanup(func() { res.Body.Close() })
+			return res
+		}
+
+		require.Equal(t, http.StatusOK, do("POST", "/post", true).StatusCode)
+		require.Equal(t, http.StatusMethodNotAllowed, do("GET", "/post", true).StatusCode)
+		require.Equal(t, http.StatusInternalServerError, do("GET", "/panic", true).StatusCode)
+		require.Equal(t, http.StatusServiceUnavailable, do("GET", "/slow", true).StatusCode)
+		require.Equal(t, http.StatusOK, do("GET", "/open", false).StatusCode)
+		require.Equal(t, http.StatusUnauthorized, do("POST", "/post", false).StatusCode)
+
+		// the server survived the panic
+		res := do("GET", "/agent/routes", true)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		var index map[string][]routeInfo
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&index))
+		byPath := map[string]routeInfo{}
+		paths := []string{}
+		for _, r := range index["routes"] {
+			byPath[r.Path] = r
+			paths = append(paths, r.Path)
+		}
+		require.Equal(t, []string{"/agent/login", "/agent/login/intent", "/agent/routes", "/metrics", "/open", "/panic", "/post", "/slow"}, paths)
+		require.Equal(t, []string{http.MethodPost}, byPath["/post"].Methods)
+		require.Equal(t, "Post only", byPath["/post"].Description)
+		require.Equal(t, uint64(2), byPath["/post"].Requests)
+		require.Equal(t, uint64(0), byPath["/post"].Errors)
+		require.Equal(t, uint64(1), byPath["/panic"].Errors)
+		require.Equal(t, uint64(1), byPath["/slow"].Errors)
+		require.Equal(t, "10ms", byPath["/slow"].Timeout)
+		require.True(t, byPath["/post"].Auth)
+		require.False(t, byPath["/open"].Auth)
+
+		// the same statistics are available as telemetry
+		res = do("GET", "/metrics", true)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.Contains(t, string(body), `ipcserver_requests{path="/post"} 2`+"\n")
+		require.Contains(t, string(body), `ipcserver_request_errors{path="/panic"} 1`+"\n")
+		require.Contains(t, string(body), `ipcserver_request_latency_seconds_count{path="/slow"} 1`+"\n")
+	})
+}
+
+func TestDuplicateRoutes(t *testing.T) {
+	app := fx.New(
+		Module,
+		telemetry.Module,
+		config.MockModule,
+		log.MockModule,
+		fx.Supply(t),
+		fx.Supply(internal.BundleParams{}),
+		fx.Provide(func() Route {
+			return NewRoute("/agent/routes", "Duplicate", []string{http.MethodGet}, nil)
+		}),
+		fx.Invoke(func(Component) {}),
+		fx.NopLogger,
+	)
+	require.Error(t, app.Err())
+	require.Contains(t, app.Err().Error(), "IPC route /agent/routes is registered more than once")
+}
+
+func TestMock(t *testing.T) {
+	var server Component
+	var client ipcclient.Component
+	comptest.FxTest(t,
+		MockModule,
+		ipcclient.MockModule,
+		log.MockModule,
+		fx.Provide(func() Route {
+			return NewRoute("/echo", "Echo", []string{http.MethodGet, http.MethodPost}, func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				json.NewEncoder(w).Encode(map[string]string{"method": r.Method, "body": string(body)})
+			})
+		}),
+		fx.Populate(&server),
+		fx.Populate(&client),
+	).WithRunningApp(func() {
+		mock := server.(Mock)
+
+		res := mock.Get("/echo")
+		require.Equal(t, http.StatusOK, res.Code)
+		require.JSONEq(t, `{"method": "GET", "body": ""}`, res.Body.String())
+
+		res = mock.Post("/echo", strings.NewReader("hello"))
+		require.Equal(t, http.StatusOK, res.Code)
+		require.JSONEq(t, `{"method": "POST", "body": "hello"}`, res.Body.String())
+
+		require.Equal(t, http.StatusNotFound, mock.Get("/nope").Code)
+
+		// the route index is served, too
+		require.Equal(t, http.StatusOK, mock.Get("/agent/routes").Code)
+
+		// the mock client's requests are served by the mock server
+		var content map[string]string
+		require.NoError(t, client.GetJSON(context.Background(), "/echo", &content))
+		require.Equal(t, map[string]string{"method": "GET", "body": ""}, content)
+	})
+}
+
+func TestGRPC(t *testing.T) {
+	dir := t.TempDir()
+	port := freePort(t)
+	healthServer := grpchealth.NewServer()
+
+	var client ipcclient.Component
+	comptest.FxTest(t,
+		Module,
+		telemetry.Module,
+		ipcclient.Module,
+		config.MockModule,
+		log.MockModule,
+		fx.Supply(internal.BundleParams{AutoStart: startup.Always}),
+		fx.Invoke(func(c config.Component) {
+			c.(config.Mock).Set("cmd_port", port)
+			c.(config.Mock).Set("auth_token_file_path", filepath.Join(dir, "auth_token"))
+			c.(config.Mock).Set("ipc_cert_file_path", filepath.Join(dir, "ipc_cert.pem"))
+		}),
+		fx.Provide(func() GRPCService {
+			return NewGRPCService(&healthpb.Health_ServiceDesc, healthServer)
+		}),
+		fx.Provide(func() Route {
+			return NewRoute("/test", "Test route", []string{http.MethodGet}, func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"ok": true}`))
+			})
+		}),
+		fx.Invoke(func(Component) {}),
+		fx.Populate(&client),
+	).WithRunningApp(func() {
+		ctx := context.Background()
+
+		conn, err := client.GRPCConn(ctx)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		// gRPC calls and HTTP requests share the listener
+		res, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+		require.Equal(t, healthpb.HealthCheckResponse_SERVING, res.Status)
+
+		var content map[string]bool
+		require.NoError(t, client.GetJSON(ctx, "/test", &content))
+
+		// streaming calls work, and are ended when the server stops
+		stream, err := healthpb.NewHealthClient(conn).Watch(ctx, &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+		update, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, healthpb.HealthCheckResponse_SERVING, update.Status)
+
+		// calls without the token are rejected
+		pool, err := auth.ReadCertPool(filepath.Join(dir, "ipc_cert.pem"))
+		require.NoError(t, err)
+		unauthConn, err := grpc.Dial(fmt.Sprintf("127.0.0.1:%d", port),
+			grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{RootCAs: pool})))
+		require.NoError(t, err)
+		defer unauthConn.Close()
+		_, err = healthpb.NewHealthClient(unauthConn).Check(ctx, &healthpb.HealthCheckRequest{})
+		require.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+}
+
+func TestGRPCSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "agent.sock")
+
+	var client ipcclient.Component
+	comptest.FxTest(t,
+		Module,
+		telemetry.Module,
+		ipcclient.Module,
+		config.MockModule,
+		log.MockModule,
+		fx.Supply(internal.BundleParams{AutoStart: startup.Always}),
+		fx.Invoke(func(c config.Component) {
+			c.(config.Mock).Set("ipc_socket_path", socketPath)
+			c.(config.Mock).Set("ipc_tcp_disabled", true)
+		}),
+		fx.Provide(func() GRPCService {
+			return NewGRPCService(&healthpb.Health_ServiceDesc, grpchealth.NewServer())
+		}),
+		fx.Invoke(func(Component) {}),
+		fx.Populate(&client),
+	).WithRunningApp(func() {
+		ctx := context.Background()
+
+		conn, err := client.GRPCConn(ctx)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		res, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+		require.Equal(t, healthpb.HealthCheckResponse_SERVING, res.Status)
+	})
+}
+
+func TestBadGRPCServices(t *testing.T) {
+	newApp := func(services ...GRPCService) *fx.App {
+		opts := []fx.Option{
+			MockModule,
+			log.MockModule,
+			fx.Supply(t),
+			fx.Invoke(func(Component) {}),
+			fx.NopLogger,
+		}
+		for _, svc := range services {
+			svc := svc
+			opts = append(opts, fx.Provide(func() GRPCService { return svc }))
+		}
+		return fx.New(opts...)
+	}
+
+	app := newApp(
+		NewGRPCService(&healthpb.Health_ServiceDesc, grpchealth.NewServer()),
+		NewGRPCService(&healthpb.Health_ServiceDesc, grpchealth.NewServer()))
+	require.Error(t, app.Err())
+	require.Contains(t, app.Err().Error(), "IPC gRPC service grpc.health.v1.Health is registered more than once")
+
+	app = newApp(NewGRPCService(&healthpb.Health_ServiceDesc, "not a server"))
+	require.Error(t, app.Err())
+	require.Contains(t, app.Err().Error(), "string does not implement")
+}
+
+func TestMockGRPC(t *testing.T) {
+	var client ipcclient.Component
+	comptest.FxTest(t,
+		MockModule,
+		ipcclient.MockModule,
+		log.MockModule,
+		fx.Provide(func() GRPCService {
+			return NewGRPCService(&healthpb.Health_ServiceDesc, grpchealth.NewServer())
+		}),
+		fx.Populate(&client),
+	).WithRunningApp(func() {
+		ctx := context.Background()
+
+		conn, err := client.GRPCConn(ctx)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		res, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+		require.Equal(t, healthpb.HealthCheckResponse_SERVING, res.Status)
+	})
+}
+
+func TestSessionExpiry(t *testing.T) {
+	var s sessions
+	intent, err := s.newIntent()
+	require.NoError(t, err)
+	cookie, ok, err := s.login(intent)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	req.AddCookie(&http.Cookie{Name: sessionCookie, Value: cookie})
+	require.True(t, s.valid(req))
+
+	// expire the session; it is rejected and forgotten
+	s.cookies[cookie] = time.Now().Add(-time.Second)
+	require.False(t, s.valid(req))
+	require.NotContains(t, s.cookies, cookie)
+
+	// an expired session is pruned at the next login
+	s.cookies["stale"] = time.Now().Add(-time.Second)
+	intent, err = s.newIntent()
+	require.NoError(t, err)
+	_, ok, err = s.login(intent)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.NotContains(t, s.cookies, "stale")
+	require.Len(t, s.cookies, 1)
+}
@@ -0,0 +1,125 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package ipcserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/ipc/internal/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// grpcService is provided by other components in order to indicate gRPC
+// services that should be served via the IPC API.
+type grpcService struct {
+	// desc describes the service, and is generated from its protocol buffer
+	// definition.
+	desc *grpc.ServiceDesc
+
+	// impl implements the service's server interface.
+	impl any
+}
+
+// setServices sets the server's gRPC services, returning an error if any
+// service is registered more than once or does not implement its server
+// interface.  The gRPC library treats these conditions as fatal, so they
+// are checked here first.
+func (a *server) setServices(services []grpcService) error {
+	a.services = append([]grpcService{}, services...)
+	sort.Slice(a.services, func(i, j int) bool { return a.services[i].desc.ServiceName < a.services[j].desc.ServiceName })
+
+	seen := map[string]struct{}{}
+	for _, svc := range a.services {
+		name := svc.desc.ServiceName
+		if _, found := seen[name]; found {
+			return fmt.Errorf("IPC gRPC service %s is registered more than once", name)
+		}
+		seen[name] = struct{}{}
+
+		iface := reflect.TypeOf(svc.desc.HandlerType).Elem()
+		if !reflect.TypeOf(svc.impl).Implements(iface) {
+			return fmt.Errorf("IPC gRPC service %s: %T does not implement %v", name, svc.impl, iface)
+		}
+	}
+	return nil
+}
+
+// newGRPCServer creates a gRPC server for all services, with each call passing
+// through interceptors analogous to the HTTP middleware chain: calls are
+// logged, panics are recovered, and the auth token is checked if token is not
+// empty.  There is no per-call timeout, as most gRPC calls are streaming.
+func (a *server) newGRPCServer(token string) *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+			err = a.intercept(ctx, info.FullMethod, token, func() error {
+				resp, err = handler(ctx, req)
+				return err
+			})
+			return resp, err
+		}),
+		grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			return a.intercept(ss.Context(), info.FullMethod, token, func() error {
+				return handler(srv, ss)
+			})
+		}),
+	)
+	for _, svc := range a.services {
+		srv.RegisterService(svc.desc, svc.impl)
+	}
+	return srv
+}
+
+// intercept wraps a single gRPC call.
+func (a *server) intercept(ctx context.Context, method, token string, call func() error) (err error) {
+	start := time.Now()
+	defer func() {
+		if p := recover(); p != nil {
+			a.log.Error(fmt.Sprintf("IPC gRPC handler for %s panicked: %v", method, p))
+			a.log.Debug(fmt.Sprintf("IPC gRPC handler for %s panic stack:\n%s", method, debug.Stack()))
+			err = status.Error(codes.Internal, "Internal error handling request")
+		}
+		a.log.Debug(fmt.Sprintf("IPC gRPC call %s: %s in %s", method, status.Code(err), time.Since(start)))
+	}()
+
+	if token != "" {
+		md, _ := metadata.FromIncomingContext(ctx)
+		authorized := false
+		for _, header := range md.Get("authorization") {
+			if auth.CheckBearer(header, token) {
+				authorized = true
+			}
+		}
+		if !authorized {
+			return status.Error(codes.Unauthenticated, "Invalid or missing auth token")
+		}
+	}
+
+	return call()
+}
+
+// withGRPC returns a handler that sends gRPC requests to grpcServer, and all
+// other requests to next.  This allows gRPC and HTTP requests to share a
+// listener.  gRPC requires HTTP/2, which the TLS server negotiates
+// automatically, and which the socket server supports via h2c.
+func withGRPC(grpcServer *grpc.Server, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			grpcServer.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
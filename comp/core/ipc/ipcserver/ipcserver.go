@@ -7,93 +7,407 @@ package ipcserver
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/config"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/internal"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/ipc/internal/auth"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/ipc/internal/portfile"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/log"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/telemetry"
 	"github.com/gorilla/mux"
 	"go.uber.org/fx"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
 )
 
 type server struct {
 	// autoStart indicates that the component should do nothing.
 	autoStart bool
 
-	// port is the port on which the server is running.
+	// port is the configured TCP port; if zero, the server chooses a port
+	// and records it in the port file.
 	port int
 
-	// router is the router used by the server
-	router *mux.Router
+	// portFilePath is the path of the port file
+	portFilePath string
 
-	// server is the running server, if started
+	// tcpDisabled disables the TCP transport
+	tcpDisabled bool
+
+	// socketPath is the path of the unix socket, or empty if the socket
+	// transport is disabled
+	socketPath string
+
+	// tokenPath is the path of the auth token file
+	tokenPath string
+
+	// certPath is the path of the TLS certificate file
+	certPath string
+
+	// routes are the registered routes, sorted by path
+	routes []route
+
+	// telemetry is the telemetry component, or nil in a mock without it
+	telemetry telemetry.Component
+
+	// metrics records request metrics for all routes
+	metrics *requestMetrics
+
+	// services are the registered gRPC services, sorted by name
+	services []grpcService
+
+	// grpcServers are the gRPC servers for the running transports.  These
+	// are stopped before the HTTP servers, as otherwise streaming calls would
+	// prevent a graceful shutdown.
+	grpcServers []*grpc.Server
+
+	// server is the running TCP server, if started
 	server *http.Server
+
+	// socketServer is the running unix socket server, if started
+	socketServer *http.Server
+
+	// listeners are the listeners for the running servers.  These are closed
+	// explicitly on stop, as a server only closes its listener if it has begun
+	// serving.
+	listeners []net.Listener
+
+	// tcpAddrMu covers tcpAddr, which is read by request handlers, which may
+	// still be running when stop gives up waiting for them
+	tcpAddrMu sync.Mutex
+
+	// tcpAddr is the address of the running TCP server, if started
+	tcpAddr string
+
+	// sessions tracks browser sessions, which are accepted in place of the
+	// auth token
+	sessions sessions
+
+	// log is the log component
+	log log.Component
 }
 
+// defaultRouteTimeout is the timeout for routes that do not specify one.
+const defaultRouteTimeout = 30 * time.Second
+
 // route is provided by other components in order to indicate routes that
 // should be served via the IPC API.
 type route struct {
 	// path is the path at which this handler should be registered
 	path string
 
+	// description describes the route
+	description string
+
+	// methods are the HTTP methods this route accepts
+	methods []string
+
+	// auth is true if the route requires the auth token
+	auth bool
+
+	// timeout is the maximum duration of a request, or zero for no timeout
+	timeout time.Duration
+
 	// handler is the handler for this path.
 	handler http.HandlerFunc
 }
 
 type dependencies struct {
 	fx.In
-	Lc     fx.Lifecycle
-	Params internal.BundleParams
-	Config config.Component
-	Routes []route `group:"ipcserver"`
+	Lc        fx.Lifecycle
+	Params    internal.BundleParams
+	Config    config.Component
+	Log       log.Component
+	Telemetry telemetry.Component
+	Routes    []route       `group:"ipcserver"`
+	Services  []grpcService `group:"ipcserver_grpc"`
 }
 
-func newServer(deps dependencies) Component {
+func newServer(deps dependencies) (Component, error) {
 	a := &server{
-		autoStart: deps.Params.ShouldStart(),
-		port:      deps.Config.GetInt("cmd_port"),
-		router:    mux.NewRouter(),
+		autoStart:    deps.Params.ShouldStart(),
+		port:         deps.Config.GetInt("cmd_port"),
+		portFilePath: portfile.Path(deps.Config),
+		tcpDisabled:  deps.Config.GetBool("ipc_tcp_disabled"),
+		socketPath:   deps.Config.GetString("ipc_socket_path"),
+		tokenPath:    auth.TokenPath(deps.Config),
+		certPath:     auth.CertPath(deps.Config),
+		telemetry:    deps.Telemetry,
+		metrics:      newRequestMetrics(deps.Telemetry),
+		log:          deps.Log,
 	}
 
-	for _, r := range deps.Routes {
-		a.router.HandleFunc(r.path, r.handler)
+	err := a.setRoutes(deps.Routes)
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.setServices(deps.Services)
+	if err != nil {
+		return nil, err
 	}
 
 	deps.Lc.Append(fx.Hook{OnStart: a.start, OnStop: a.stop})
-	return a
+	return a, nil
 }
 
-func newMock() Component {
-	a := &server{
-		autoStart: false,
-		port:      0,
-		router:    mux.NewRouter(),
+// setRoutes sets the server's routes, including its own `/agent/routes`,
+// browser login, and (with telemetry) `/metrics` routes, returning an error if
+// any path is registered more than once.
+func (a *server) setRoutes(routes []route) error {
+	a.routes = append([]route{}, routes...)
+	if a.telemetry != nil {
+		a.routes = append(a.routes,
+			NewRoute("/metrics", "Internal telemetry, in Prometheus text format",
+				[]string{http.MethodGet}, a.metricsHandler).Route)
 	}
-	return a
+	a.routes = append(a.routes,
+		NewRoute("/agent/routes", "List the routes served by the IPC API",
+			[]string{http.MethodGet}, a.routesHandler).Route,
+		NewRoute("/agent/login/intent", "Create a one-time browser login URL",
+			[]string{http.MethodPost}, a.loginIntentHandler).Route,
+		NewRoute("/agent/login", "Log in a browser with a one-time login intent",
+			[]string{http.MethodGet}, a.loginHandler).WithoutAuth().Route)
+	sort.Slice(a.routes, func(i, j int) bool { return a.routes[i].path < a.routes[j].path })
+
+	for i := 1; i < len(a.routes); i++ {
+		if a.routes[i].path == a.routes[i-1].path {
+			return fmt.Errorf("IPC route %s is registered more than once", a.routes[i].path)
+		}
+	}
+	return nil
+}
+
+// newRouter creates a router serving all routes, with each route's handler
+// wrapped in the middleware chain.  If token is empty, no route requires
+// authentication.
+func (a *server) newRouter(token string) *mux.Router {
+	router := mux.NewRouter()
+	for _, r := range a.routes {
+		rt := router.Handle(r.path, a.wrap(r, token))
+		if len(r.methods) > 0 {
+			rt.Methods(r.methods...)
+		}
+	}
+	return router
+}
+
+// routeInfo describes a route in the `/agent/routes` index.
+type routeInfo struct {
+	Path        string   `json:"path"`
+	Description string   `json:"description"`
+	Methods     []string `json:"methods"`
+	Auth        bool     `json:"auth"`
+	Timeout     string   `json:"timeout"`
+	Requests    uint64   `json:"requests"`
+	Errors      uint64   `json:"errors"`
+	MeanLatency string   `json:"mean_latency"`
+}
+
+// routesHandler serves the /agent/routes endpoint, returning
+// {"routes": [..]}, sorted by path.
+func (a *server) routesHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header()["Content-Type"] = []string{"application/json; charset=UTF-8"}
+
+	infos := make([]routeInfo, 0, len(a.routes))
+	for _, r := range a.routes {
+		requests, errors, meanLatency := a.metrics.get(r.path)
+		infos = append(infos, routeInfo{
+			Path:        r.path,
+			Description: r.description,
+			Methods:     r.methods,
+			Auth:        r.auth,
+			Timeout:     r.timeout.String(),
+			Requests:    requests,
+			Errors:      errors,
+			MeanLatency: meanLatency.String(),
+		})
+	}
+
+	json.NewEncoder(w).Encode(map[string][]routeInfo{"routes": infos})
+}
+
+// metricsHandler serves the /metrics endpoint.
+func (a *server) metricsHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header()["Content-Type"] = []string{"text/plain; version=0.0.4; charset=utf-8"}
+	a.telemetry.WriteText(w)
 }
 
-// start starts the http server, if autoStart is true.
+// start starts the enabled transports, if autoStart is true.  Listeners are
+// bound before this returns, so failures such as port conflicts fail startup.
+// If one transport fails, any that were started are stopped.
 func (a *server) start(ctx context.Context) error {
 	if !a.autoStart {
 		return nil
 	}
 
-	a.server = &http.Server{
-		Addr:    fmt.Sprintf("127.0.0.1:%d", a.port),
-		Handler: a.router,
+	if a.socketPath != "" {
+		if err := a.startSocket(); err != nil {
+			return err
+		}
 	}
-	go a.server.ListenAndServe()
+
+	if !a.tcpDisabled {
+		if err := a.startTCP(); err != nil {
+			a.stop(ctx)
+			return err
+		}
+	}
+
 	return nil
 }
 
-// stop stops the http server, if started
-func (a *server) stop(ctx context.Context) error {
-	if a.server != nil {
-		defer func() { a.server = nil }()
-		err := a.server.Shutdown(ctx)
+// startTCP starts the https server, serving both routes and gRPC services,
+// creating the auth token and certificate if necessary.
+func (a *server) startTCP() error {
+	token, err := auth.FetchOrCreateToken(a.tokenPath)
+	if err != nil {
+		return err
+	}
+
+	cert, err := auth.FetchOrCreateCert(a.certPath)
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", a.port))
+	if err != nil {
+		return fmt.Errorf("Could not start IPC server: %w", err)
+	}
+
+	if a.port == 0 {
+		err = portfile.Write(a.portFilePath, listener.Addr().(*net.TCPAddr).Port)
 		if err != nil {
+			listener.Close()
 			return err
 		}
 	}
+
+	grpcServer := a.newGRPCServer(token)
+	a.grpcServers = append(a.grpcServers, grpcServer)
+	a.server = &http.Server{
+		Handler: withGRPC(grpcServer, a.newRouter(token)),
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		},
+	}
+	a.listeners = append(a.listeners, listener)
+	a.setTCPAddr(listener.Addr().String())
+	go a.server.ServeTLS(listener, "", "")
+	a.log.Info(fmt.Sprintf("IPC server listening on https://%s", listener.Addr()))
 	return nil
 }
+
+// startSocket starts the http server on the unix socket, serving both routes
+// and gRPC services, accessible only to the agent's user.  Access to the
+// socket is controlled by its file permissions, so this transport does not use
+// TLS or the auth token.
+func (a *server) startSocket() error {
+	// remove any socket left behind by a previous run
+	if err := os.Remove(a.socketPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Could not remove stale IPC socket: %w", err)
+	}
+
+	listener, err := listenPrivate(a.socketPath)
+	if err != nil {
+		return err
+	}
+
+	// gRPC requires HTTP/2, which is only negotiated automatically with TLS
+	grpcServer := a.newGRPCServer("")
+	a.grpcServers = append(a.grpcServers, grpcServer)
+	a.socketServer = &http.Server{Handler: h2c.NewHandler(withGRPC(grpcServer, a.newRouter("")), &http2.Server{})}
+	a.listeners = append(a.listeners, listener)
+	go a.socketServer.Serve(listener)
+	a.log.Info(fmt.Sprintf("IPC server listening on unix socket %s", a.socketPath))
+	return nil
+}
+
+// listenPrivate listens on a unix socket at path, accessible only to the
+// current user.  The socket is created in a new directory accessible only to
+// the current user, and moved into place once its permissions are set, so that
+// it is never accessible to other users.  The socket file is not removed when
+// the listener is closed.
+func listenPrivate(path string) (net.Listener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".ipc-socket-*") // created with mode 0700
+	if err != nil {
+		return nil, fmt.Errorf("Could not create IPC socket directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tmpPath := filepath.Join(dir, "socket")
+	listener, err := net.Listen("unix", tmpPath)
+	if err != nil {
+		return nil, fmt.Errorf("Could not listen on IPC socket: %w", err)
+	}
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+
+	if err := os.Chmod(tmpPath, 0o600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("Could not set IPC socket permissions: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("Could not move IPC socket into place: %w", err)
+	}
+	return listener, nil
+}
+
+// stop stops the servers, if started, removing the port file if it was
+// written, and the unix socket.
+func (a *server) stop(ctx context.Context) error {
+	if a.server != nil && a.port == 0 {
+		os.Remove(a.portFilePath)
+	}
+	if a.socketServer != nil {
+		os.Remove(a.socketPath)
+	}
+
+	for _, srv := range a.grpcServers {
+		srv.Stop()
+	}
+
+	var firstErr error
+	for _, srv := range []*http.Server{a.server, a.socketServer} {
+		if srv != nil {
+			if err := srv.Shutdown(ctx); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	for _, l := range a.listeners {
+		// this may fail if the server already closed the listener
+		l.Close()
+	}
+	a.server, a.socketServer, a.listeners, a.grpcServers = nil, nil, nil, nil
+	a.setTCPAddr("")
+	return firstErr
+}
+
+// getTCPAddr gets the address of the running TCP server, or an empty string
+// if it is not running.
+func (a *server) getTCPAddr() string {
+	a.tcpAddrMu.Lock()
+	defer a.tcpAddrMu.Unlock()
+	return a.tcpAddr
+}
+
+// setTCPAddr sets the address of the running TCP server.
+func (a *server) setTCPAddr(addr string) {
+	a.tcpAddrMu.Lock()
+	defer a.tcpAddrMu.Unlock()
+	a.tcpAddr = addr
+}
@@ -0,0 +1,150 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package ipcserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/telemetry"
+)
+
+// wrap wraps the route's handler in the middleware chain.  From the outside
+// in, the chain logs and counts requests, recovers panics, checks the auth
+// token or session cookie (if token is not empty and the route requires it),
+// and applies the route's timeout.
+func (a *server) wrap(r route, token string) http.Handler {
+	var h http.Handler = r.handler
+	if r.timeout > 0 {
+		h = http.TimeoutHandler(h, r.timeout, `{"error": "Request timed out"}`)
+	}
+	if token != "" && r.auth {
+		h = a.requireAuth(token, h)
+	}
+	h = a.recoverPanics(h)
+	h = a.logRequests(r.path, h)
+	return h
+}
+
+// requestMetrics holds the telemetry metrics for requests, tagged by route
+// path.  A nil *requestMetrics records nothing.
+type requestMetrics struct {
+	// requests counts requests handled
+	requests telemetry.Counter
+
+	// errors counts requests resulting in a 5xx response
+	errors telemetry.Counter
+
+	// latency records the time spent handling requests, in seconds
+	latency telemetry.Histogram
+}
+
+// newRequestMetrics creates the request metrics.
+func newRequestMetrics(tel telemetry.Component) *requestMetrics {
+	return &requestMetrics{
+		requests: tel.NewCounter("ipcserver", "requests", []string{"path"}, "IPC API requests handled"),
+		errors:   tel.NewCounter("ipcserver", "request_errors", []string{"path"}, "IPC API requests resulting in a 5xx response"),
+		latency: tel.NewHistogram("ipcserver", "request_latency_seconds", []string{"path"},
+			"Time spent handling IPC API requests", nil),
+	}
+}
+
+// record records a request to the route at path.
+func (m *requestMetrics) record(path string, status int, latency time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.requests.Inc(path)
+	if status >= 500 {
+		m.errors.Inc(path)
+	}
+	m.latency.Observe(latency.Seconds(), path)
+}
+
+// get gets the request count, error count, and mean latency for the route at
+// path.
+func (m *requestMetrics) get(path string) (uint64, uint64, time.Duration) {
+	if m == nil {
+		return 0, 0, 0
+	}
+
+	var mean time.Duration
+	count, sum := m.latency.Summary(path)
+	if count > 0 {
+		mean = time.Duration(sum / float64(count) * float64(time.Second))
+	}
+	return uint64(m.requests.Value(path)), uint64(m.errors.Value(path)), mean
+}
+
+// statusRecorder records the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher, so that streaming handlers can flush
+// through this wrapper.
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// logRequests logs each request to the route at path, and records it in the
+// request metrics.
+func (a *server) logRequests(path string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		latency := time.Since(start)
+		a.metrics.record(path, rec.status, latency)
+		a.log.Debug(fmt.Sprintf("IPC request %s %s: %d in %s", r.Method, r.URL.Path, rec.status, latency))
+	})
+}
+
+// recoverPanics recovers panics in the handler, logging them and responding
+// with a 500 error.
+func (a *server) recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if p := recover(); p != nil {
+				// ErrAbortHandler is used to deliberately abort a response
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+				a.log.Error(fmt.Sprintf("IPC handler for %s panicked: %v", r.URL.Path, p))
+				a.log.Debug(fmt.Sprintf("IPC handler for %s panic stack:\n%s", r.URL.Path, debug.Stack()))
+				w.Header()["Content-Type"] = []string{"application/json; charset=UTF-8"}
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(map[string]string{"error": "Internal error handling request"})
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,108 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package ipcserver
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/ipc/internal/mockhandler"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/log"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/telemetry"
+	"github.com/gorilla/mux"
+	"go.uber.org/fx"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// mock implements Mock, serving requests in-process.
+type mock struct {
+	*server
+
+	// router serves all routes, without authentication
+	router *mux.Router
+
+	// grpcServer serves all gRPC services, without authentication, on
+	// grpcListener
+	grpcServer   *grpc.Server
+	grpcListener *bufconn.Listener
+}
+
+// mockBufferSize is the size of the in-process gRPC connection buffers.
+const mockBufferSize = 1024 * 1024
+
+type mockDependencies struct {
+	fx.In
+
+	Lc        fx.Lifecycle
+	Log       log.Component
+	Telemetry telemetry.Component `optional:"true"`
+	Routes    []route             `group:"ipcserver"`
+	Services  []grpcService       `group:"ipcserver_grpc"`
+}
+
+type mockProvides struct {
+	fx.Out
+
+	Component
+	Handler mockhandler.Handler
+}
+
+func newMock(deps mockDependencies) (mockProvides, error) {
+	s := &server{log: deps.Log, telemetry: deps.Telemetry}
+	if deps.Telemetry != nil {
+		s.metrics = newRequestMetrics(deps.Telemetry)
+	}
+	err := s.setRoutes(deps.Routes)
+	if err != nil {
+		return mockProvides{}, err
+	}
+
+	err = s.setServices(deps.Services)
+	if err != nil {
+		return mockProvides{}, err
+	}
+
+	m := &mock{
+		server:       s,
+		router:       s.newRouter(""),
+		grpcServer:   s.newGRPCServer(""),
+		grpcListener: bufconn.Listen(mockBufferSize),
+	}
+	deps.Lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go m.grpcServer.Serve(m.grpcListener)
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			m.grpcServer.Stop()
+			return nil
+		},
+	})
+
+	return mockProvides{
+		Component: m,
+		Handler:   mockhandler.Handler{Handler: m.router, DialGRPC: m.grpcListener.DialContext},
+	}, nil
+}
+
+// Get implements Mock#Get.
+func (m *mock) Get(path string) *httptest.ResponseRecorder {
+	return m.do(httptest.NewRequest(http.MethodGet, path, nil))
+}
+
+// Post implements Mock#Post.
+func (m *mock) Post(path string, body io.Reader) *httptest.ResponseRecorder {
+	return m.do(httptest.NewRequest(http.MethodPost, path, body))
+}
+
+func (m *mock) do(req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	m.router.ServeHTTP(rec, req)
+	return rec
+}
@@ -0,0 +1,221 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package ipcserver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/ipc/internal/auth"
+)
+
+const (
+	// sessionCookie is the name of the cookie carrying a browser session.
+	sessionCookie = "dd_ipc_session"
+
+	// intentTimeout is the time within which a login intent must be used.
+	intentTimeout = 5 * time.Minute
+
+	// sessionLifetime is the time for which a session cookie is valid.
+	sessionLifetime = time.Hour
+)
+
+// sessions tracks browser sessions.  A browser cannot send the auth token, so
+// a client holding the token creates a one-time login intent, and a browser
+// exchanges that intent for a session cookie, which is then accepted in place
+// of the token, until it expires.
+type sessions struct {
+	sync.Mutex
+
+	// intents maps unused login intents to their expiry time
+	intents map[string]time.Time
+
+	// cookies maps the values of session cookies to their expiry time
+	cookies map[string]time.Time
+}
+
+// newIntent creates a new one-time login intent.
+func (s *sessions) newIntent() (string, error) {
+	intent, err := randomHex()
+	if err != nil {
+		return "", err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if s.intents == nil {
+		s.intents = map[string]time.Time{}
+	}
+	now := time.Now()
+	pruneExpired(s.intents, now)
+	s.intents[intent] = now.Add(intentTimeout)
+	return intent, nil
+}
+
+// login exchanges a login intent for a new session cookie value, valid for
+// sessionLifetime.  It returns false if the intent is unknown, expired, or
+// already used.
+func (s *sessions) login(intent string) (string, bool, error) {
+	s.Lock()
+	expiry, found := s.intents[intent]
+	delete(s.intents, intent)
+	s.Unlock()
+
+	if !found || time.Now().After(expiry) {
+		return "", false, nil
+	}
+
+	cookie, err := randomHex()
+	if err != nil {
+		return "", false, err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if s.cookies == nil {
+		s.cookies = map[string]time.Time{}
+	}
+	now := time.Now()
+	pruneExpired(s.cookies, now)
+	s.cookies[cookie] = now.Add(sessionLifetime)
+	return cookie, true, nil
+}
+
+// valid determines whether the request carries a valid, unexpired session
+// cookie.  Expired cookies are forgotten.
+func (s *sessions) valid(r *http.Request) bool {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return false
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	expiry, found := s.cookies[c.Value]
+	if !found {
+		return false
+	}
+	if time.Now().After(expiry) {
+		delete(s.cookies, c.Value)
+		return false
+	}
+	return true
+}
+
+// pruneExpired deletes the entries in m that expired before now.
+func pruneExpired(m map[string]time.Time, now time.Time) {
+	for k, expiry := range m {
+		if now.After(expiry) {
+			delete(m, k)
+		}
+	}
+}
+
+// randomHex returns a random, hex-encoded, 32-byte value.
+func randomHex() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("Could not generate session value: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// localPath determines whether next is a path on this server, and so safe to
+// redirect to.
+func localPath(next string) bool {
+	return strings.HasPrefix(next, "/") && !strings.HasPrefix(next, "//") && !strings.HasPrefix(next, "/\\")
+}
+
+// requireAuth wraps the given handler, accepting requests carrying the given
+// token as a bearer token, or a valid session cookie.
+func (a *server) requireAuth(token string, next http.Handler) http.Handler {
+	withToken := auth.RequireToken(token, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.sessions.valid(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		withToken.ServeHTTP(w, r)
+	})
+}
+
+// loginIntentHandler serves the /agent/login/intent endpoint, creating a
+// one-time login intent for a browser.  The `next` query parameter gives the
+// path to which the browser is redirected after logging in.  On success, this
+// returns {"url": <url>}, the URL the browser should open.
+func (a *server) loginIntentHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header()["Content-Type"] = []string{"application/json; charset=UTF-8"}
+
+	next := r.URL.Query().Get("next")
+	if !localPath(next) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "next must be a path on this server"})
+		return
+	}
+
+	tcpAddr := a.getTCPAddr()
+	if tcpAddr == "" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Browser login requires the TCP transport"})
+		return
+	}
+
+	intent, err := a.sessions.newIntent()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	query := url.Values{}
+	query.Set("intent", intent)
+	query.Set("next", next)
+	loginURL := fmt.Sprintf("https://%s/agent/login?%s", tcpAddr, query.Encode())
+	json.NewEncoder(w).Encode(map[string]string{"url": loginURL})
+}
+
+// loginHandler serves the /agent/login endpoint, which does not require the
+// auth token.  It exchanges the `intent` query parameter for a session cookie
+// and redirects to the `next` query parameter.
+func (a *server) loginHandler(w http.ResponseWriter, r *http.Request) {
+	next := r.URL.Query().Get("next")
+	if !localPath(next) {
+		http.Error(w, "Invalid next path", http.StatusBadRequest)
+		return
+	}
+
+	cookie, ok, err := a.sessions.login(r.URL.Query().Get("intent"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "Invalid, expired, or already-used login intent", http.StatusUnauthorized)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    cookie,
+		Path:     "/",
+		MaxAge:   int(sessionLifetime / time.Second),
+		Expires:  time.Now().Add(sessionLifetime),
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
@@ -26,6 +26,15 @@ type Component interface {
 	// Debug logs at the debug level.
 	Debug(v ...interface{})
 
+	// Info logs at the info level.
+	Info(v ...interface{})
+
+	// Warn logs at the warning level.
+	Warn(v ...interface{})
+
+	// Error logs at the error level.
+	Error(v ...interface{})
+
 	// Flush flushes the underlying inner log
 	Flush()
 
@@ -46,6 +46,30 @@ func (l *logger) Debug(v ...interface{}) {
 	}
 }
 
+// Info implements Component#Info.
+func (l *logger) Info(v ...interface{}) {
+	// stand-in, to avoid messing with seelog
+	if l.console {
+		fmt.Println(v...)
+	}
+}
+
+// Warn implements Component#Warn.
+func (l *logger) Warn(v ...interface{}) {
+	// stand-in, to avoid messing with seelog
+	if l.console {
+		fmt.Println(v...)
+	}
+}
+
+// Error implements Component#Error.
+func (l *logger) Error(v ...interface{}) {
+	// stand-in, to avoid messing with seelog
+	if l.console {
+		fmt.Println(v...)
+	}
+}
+
 // Flush implements Component#Flush.
 func (*logger) Flush() {
 	// do nothing
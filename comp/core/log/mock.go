@@ -32,6 +32,26 @@ func (m *mock) Configure(level string) error {
 
 // Debug implements Component#Debug.
 func (m *mock) Debug(v ...interface{}) {
+	m.log(v...)
+}
+
+// Info implements Component#Info.
+func (m *mock) Info(v ...interface{}) {
+	m.log(v...)
+}
+
+// Warn implements Component#Warn.
+func (m *mock) Warn(v ...interface{}) {
+	m.log(v...)
+}
+
+// Error implements Component#Error.
+func (m *mock) Error(v ...interface{}) {
+	m.log(v...)
+}
+
+// log logs and captures a message, regardless of level.
+func (m *mock) log(v ...interface{}) {
 	m.Lock()
 	defer m.Unlock()
 
@@ -6,13 +6,33 @@
 // Package status implements the functionality behind `agent status`.
 //
 // The data included in the status output is provided by other components, by providing a
-// *status.Registration instance in value-group "health".  Nil *status.Registrations will
+// *status.Registration instance in value-group "status".  Nil *status.Registrations will
 // be ignored, assuming they are for disabled components.
 //
+// The registered sections can be listed with ListSections, or via the IPC API
+// at `/agent/status/sections`.  The status itself is available via the IPC API
+// at `/agent/status`, with optional `format` and (repeated) `section` query
+// parameters.  Unknown sections result in a 404 response.
+//
+// Each registration supplies a callback returning structured, JSON-serializable
+// data for its section, and a text/template that renders that data as text.
+// Status can then be rendered in any of the supported formats: TextFormat, using
+// the section templates; JSONFormat, containing the structured data; and
+// HTMLFormat, containing the text rendering of each section in an HTML page.
+//
+// Section callbacks are called concurrently, each in its own goroutine, with a
+// context that is cancelled after a few seconds.  A callback that panics, or that
+// does not return before its context is done, results in an error for that
+// section, without affecting other sections.
+//
 // All of the component's methods can be called concurrently.
 package status
 
 import (
+	"context"
+	"errors"
+	"text/template"
+
 	"go.uber.org/fx"
 )
 
@@ -22,9 +42,64 @@ const componentName = "comp/core/status"
 
 // Component is the component type.
 type Component interface {
-	// GetStatus gets the agent status.  If the section parameter is not empty, then
-	// only that section's status is returned.  This is a newline-terminated string.
-	GetStatus(section string) string
+	// ListSections lists the registered sections, in the order in which they
+	// are rendered.  The returned slice is a copy and will not be modified
+	// after return.
+	ListSections() []SectionInfo
+
+	// GetStatus gets the structured agent status, ordered by section order
+	// and then by name.  Section callbacks are given a context derived from
+	// ctx, and sections whose callbacks fail, panic, or time out contain an
+	// Error.  If the sections parameter is not empty, then only
+	// those sections' status is returned.  This returns an error wrapping
+	// ErrUnknownSection if any of the given sections is not registered.
+	// The returned slice is a copy, but the Data in each section is shared with
+	// the providing component and must not be modified.
+	GetStatus(ctx context.Context, sections []string) ([]Section, error)
+
+	// Render renders the agent status in the given format.  If the sections
+	// parameter is not empty, then only those sections' status is rendered.
+	// This returns an error if the format is not supported, or an error
+	// wrapping ErrUnknownSection if any of the given sections is not
+	// registered.
+	Render(ctx context.Context, sections []string, format string) (string, error)
+}
+
+// ErrUnknownSection is wrapped by errors returned when a requested section is
+// not registered.
+var ErrUnknownSection = errors.New("Unknown status section")
+
+// The supported formats for Component#Render.
+const (
+	// TextFormat renders each section with its text template.
+	TextFormat = "text"
+
+	// JSONFormat renders the structured data for all sections as JSON.
+	JSONFormat = "json"
+
+	// HTMLFormat renders each section with its text template, in an HTML page.
+	HTMLFormat = "html"
+)
+
+// SectionInfo describes a registered section.
+type SectionInfo struct {
+	// Name is the name of the section.
+	Name string `json:"name"`
+
+	// Description is a short, human-readable description of the section.
+	Description string `json:"description"`
+}
+
+// Section is the status of a single section.
+type Section struct {
+	// Name is the name of the section.
+	Name string `json:"name"`
+
+	// Data is the structured data returned by the section's callback.
+	Data any `json:"data,omitempty"`
+
+	// Error is the error returned by the section's callback, if any.
+	Error string `json:"error,omitempty"`
 }
 
 // Registration is provided by other components to register themselves to
@@ -37,13 +112,25 @@ type Registration struct {
 
 // NewRegistration creates a new Registration.
 //
-// The section name allows users to select a single section for output (`agent
-// status <section-name>`). When all sections are included, they are ordered by
-// `order`.  The `cb` returns the text of the section, including the header. If
-// an error occurs in `cb`, it should include the error message in its output.
-func NewRegistration(section string, order int, cb func() string) Registration {
+// The section name allows users to select sections for output (`agent status
+// <section-name>..`), and must be unique; duplicate section names cause an
+// error at startup.  The description is a short, human-readable description
+// of the section, shown when listing sections.  When all sections are included,
+// they are ordered by `order`, and then by name.  The `cb` returns the data
+// for the section, which must be serializable as JSON, or an error.  It may be
+// called concurrently with other callbacks, and should return promptly once
+// its context is done.  The `textTemplate` is a text/template which renders
+// that data as text, including the section header.  This function panics if
+// the template cannot be parsed.
+func NewRegistration(section, description string, order int, cb func(ctx context.Context) (any, error), textTemplate string) Registration {
 	return Registration{
-		Registration: registration{section, order, cb},
+		Registration: registration{
+			section:     section,
+			description: description,
+			order:       order,
+			cb:          cb,
+			tmpl:        template.Must(template.New(section).Parse(textTemplate)),
+		},
 	}
 }
 
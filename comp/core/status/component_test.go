@@ -0,0 +1,258 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/log"
+	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/comptest"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+)
+
+type fruitData struct {
+	Fruit string `json:"fruit"`
+}
+
+func testRegistrations() fx.Option {
+	return fx.Options(
+		fx.Provide(func() Registration {
+			return NewRegistration("second", "The second section", 2, func(context.Context) (any, error) {
+				return fruitData{Fruit: "banana"}, nil
+			}, "Second: {{ .Fruit }}\n")
+		}),
+		fx.Provide(func() Registration {
+			return NewRegistration("first", "The first section", 1, func(context.Context) (any, error) {
+				return fruitData{Fruit: "apple"}, nil
+			}, "First: {{ .Fruit }}\n")
+		}),
+		fx.Provide(func() Registration {
+			return NewRegistration("broken", "A broken section", 1, func(context.Context) (any, error) {
+				return nil, errors.New("uhoh")
+			}, "never rendered\n")
+		}),
+	)
+}
+
+func TestGetStatus(t *testing.T) {
+	var status Component
+	comptest.FxTest(t,
+		Module,
+		log.MockModule,
+		testRegistrations(),
+		fx.Populate(&status),
+	).WithRunningApp(func() {
+		// sections are ordered by order, then name
+		sections, err := status.GetStatus(context.Background(), nil)
+		require.NoError(t, err)
+		require.Equal(t, []Section{
+			{Name: "broken", Error: "uhoh"},
+			{Name: "first", Data: fruitData{Fruit: "apple"}},
+			{Name: "second", Data: fruitData{Fruit: "banana"}},
+		}, sections)
+
+		sections, err = status.GetStatus(context.Background(), []string{"second"})
+		require.NoError(t, err)
+		require.Equal(t, []Section{
+			{Name: "second", Data: fruitData{Fruit: "banana"}},
+		}, sections)
+
+		sections, err = status.GetStatus(context.Background(), []string{"second", "first"})
+		require.NoError(t, err)
+		require.Equal(t, []Section{
+			{Name: "first", Data: fruitData{Fruit: "apple"}},
+			{Name: "second", Data: fruitData{Fruit: "banana"}},
+		}, sections)
+
+		_, err = status.GetStatus(context.Background(), []string{"first", "bogus"})
+		require.ErrorIs(t, err, ErrUnknownSection)
+		require.Contains(t, err.Error(), "bogus")
+	})
+}
+
+func TestRender(t *testing.T) {
+	var status Component
+	comptest.FxTest(t,
+		Module,
+		log.MockModule,
+		testRegistrations(),
+		fx.Populate(&status),
+	).WithRunningApp(func() {
+		text, err := status.Render(context.Background(), nil, TextFormat)
+		require.NoError(t, err)
+		require.Equal(t,
+			"Error getting status for section broken: uhoh\n\nFirst: apple\n\nSecond: banana\n\n\n",
+			text)
+
+		text, err = status.Render(context.Background(), []string{"first"}, TextFormat)
+		require.NoError(t, err)
+		require.Equal(t, "First: apple\n\n\n", text)
+
+		js, err := status.Render(context.Background(), []string{"first"}, JSONFormat)
+		require.NoError(t, err)
+		var sections []map[string]any
+		require.NoError(t, json.Unmarshal([]byte(js), &sections))
+		require.Equal(t, []map[string]any{
+			{"name": "first", "data": map[string]any{"fruit": "apple"}},
+		}, sections)
+
+		html, err := status.Render(context.Background(), []string{"second"}, HTMLFormat)
+		require.NoError(t, err)
+		require.Contains(t, html, `<div class="section" id="second">`)
+		require.Contains(t, html, "<pre>Second: banana\n</pre>")
+
+		_, err = status.Render(context.Background(), nil, "yaml")
+		require.Error(t, err)
+
+		_, err = status.Render(context.Background(), []string{"bogus"}, TextFormat)
+		require.ErrorIs(t, err, ErrUnknownSection)
+	})
+}
+
+func TestListSections(t *testing.T) {
+	var status Component
+	comptest.FxTest(t,
+		Module,
+		log.MockModule,
+		testRegistrations(),
+		fx.Populate(&status),
+	).WithRunningApp(func() {
+		require.Equal(t, []SectionInfo{
+			{Name: "broken", Description: "A broken section"},
+			{Name: "first", Description: "The first section"},
+			{Name: "second", Description: "The second section"},
+		}, status.ListSections())
+	})
+}
+
+func TestIPCHandler(t *testing.T) {
+	var comp Component
+	comptest.FxTest(t,
+		Module,
+		log.MockModule,
+		testRegistrations(),
+		fx.Populate(&comp),
+	).WithRunningApp(func() {
+		s := comp.(*status)
+
+		w := httptest.NewRecorder()
+		s.ipcHandler(w, httptest.NewRequest("GET", "/agent/status?section=first&section=second", nil))
+		require.Equal(t, 200, w.Code)
+		var content map[string]string
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &content))
+		require.Equal(t, "First: apple\n\nSecond: banana\n\n\n", content["status"])
+
+		w = httptest.NewRecorder()
+		s.ipcHandler(w, httptest.NewRequest("GET", "/agent/status?section=bogus", nil))
+		require.Equal(t, 404, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &content))
+		require.Contains(t, content["error"], "bogus")
+
+		w = httptest.NewRecorder()
+		s.sectionsIPCHandler(w, httptest.NewRequest("GET", "/agent/status/sections", nil))
+		require.Equal(t, 200, w.Code)
+		var sections map[string][]SectionInfo
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sections))
+		require.Equal(t, s.ListSections(), sections["sections"])
+	})
+}
+
+func TestDuplicateSections(t *testing.T) {
+	app := fx.New(
+		Module,
+		log.MockModule,
+		fx.Supply(t),
+		testRegistrations(),
+		fx.Provide(func() Registration {
+			return NewRegistration("first", "Another first section", 5, func(context.Context) (any, error) {
+				return nil, nil
+			}, "")
+		}),
+		fx.Invoke(func(Component) {}),
+		fx.NopLogger,
+	)
+	require.Error(t, app.Err())
+	require.Contains(t, app.Err().Error(), "Status section first is registered more than once")
+}
+
+func TestMisbehavingSections(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	var status Component
+	comptest.FxTest(t,
+		Module,
+		log.MockModule,
+		testRegistrations(),
+		fx.Provide(func() Registration {
+			return NewRegistration("panicky", "A panicking section", 3, func(context.Context) (any, error) {
+				panic("oh no")
+			}, "never rendered\n")
+		}),
+		fx.Provide(func() Registration {
+			return NewRegistration("stuck", "A section that ignores its context", 3, func(context.Context) (any, error) {
+				<-release
+				return nil, nil
+			}, "never rendered\n")
+		}),
+		fx.Populate(&status),
+	).WithRunningApp(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		sections, err := status.GetStatus(ctx, nil)
+		require.NoError(t, err)
+		require.Equal(t, []Section{
+			{Name: "broken", Error: "uhoh"},
+			{Name: "first", Data: fruitData{Fruit: "apple"}},
+			{Name: "second", Data: fruitData{Fruit: "banana"}},
+			{Name: "panicky", Error: "panic: oh no"},
+			{Name: "stuck", Error: "timed out: context deadline exceeded"},
+		}, sections)
+	})
+}
+
+func TestConcurrentSections(t *testing.T) {
+	// "waiter" cannot return until "signaler" has been called, so this
+	// only succeeds if the callbacks are called concurrently.
+	signal := make(chan struct{})
+
+	var status Component
+	comptest.FxTest(t,
+		Module,
+		log.MockModule,
+		fx.Provide(func() Registration {
+			return NewRegistration("waiter", "Waits for signaler", 1, func(ctx context.Context) (any, error) {
+				select {
+				case <-signal:
+					return "waited", nil
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+			}, "{{ . }}\n")
+		}),
+		fx.Provide(func() Registration {
+			return NewRegistration("signaler", "Signals waiter", 2, func(context.Context) (any, error) {
+				close(signal)
+				return "signaled", nil
+			}, "{{ . }}\n")
+		}),
+		fx.Populate(&status),
+	).WithRunningApp(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		text, err := status.Render(ctx, nil, TextFormat)
+		require.NoError(t, err)
+		require.Equal(t, "waited\n\nsignaled\n\n\n", text)
+	})
+}
@@ -5,22 +5,55 @@
 
 package status
 
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
 // Registration is provided by other components in order to register sections
 // for status reporting.
 type registration struct {
 	// section is the name of the status section
 	section string
 
+	// description describes the status section
+	description string
+
 	// order determines the order of the sections
 	order int
 
-	// cb generates the content of the section
-	cb func() string
+	// cb generates the data for the section
+	cb func(ctx context.Context) (any, error)
+
+	// tmpl renders the data for the section as text
+	tmpl *template.Template
 }
 
-// byOrder supports sorting sections by order.
+// byOrder supports sorting sections by order, and then by name.
 type byOrder []registration
 
-func (a byOrder) Len() int           { return len(a) }
-func (a byOrder) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a byOrder) Less(i, j int) bool { return a[i].order < a[j].order }
+func (a byOrder) Len() int      { return len(a) }
+func (a byOrder) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byOrder) Less(i, j int) bool {
+	if a[i].order != a[j].order {
+		return a[i].order < a[j].order
+	}
+	return a[i].section < a[j].section
+}
+
+// renderText renders the section's status as text, using the section's
+// template.
+func (r registration) renderText(section Section) string {
+	if section.Error != "" {
+		return fmt.Sprintf("Error getting status for section %s: %s\n", r.section, section.Error)
+	}
+
+	var bldr strings.Builder
+	err := r.tmpl.Execute(&bldr, section.Data)
+	if err != nil {
+		return fmt.Sprintf("Error rendering status for section %s: %s\n", r.section, err)
+	}
+	return bldr.String()
+}
@@ -6,29 +6,41 @@
 package status
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html/template"
 	"net/http"
+	"runtime/debug"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/flare"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/ipc/ipcserver"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/log"
 	"go.uber.org/fx"
 )
 
-type status struct {
-	// Mutex covers all fields, including all componentHealth values
-	sync.Mutex
+// sectionTimeout is the maximum time a section callback may run.
+const sectionTimeout = 5 * time.Second
 
-	// sections maps component package path to that component's current status
+type status struct {
+	// sections contains the registered sections, sorted by order.  This is
+	// not modified after construction, so requires no locking.
 	sections []registration
+
+	// log is the log component
+	log log.Component
 }
 
 type dependencies struct {
 	fx.In
 
 	Lc            fx.Lifecycle
+	Log           log.Component
 	Registrations []registration `group:"status"`
 }
 
@@ -36,19 +48,34 @@ type provides struct {
 	fx.Out
 
 	Component
-	FlareReg flare.Registration
-	IPCRoute ipcserver.Route
+	FlareReg         flare.Registration
+	TextFlareReg     flare.Registration
+	IPCRoute         ipcserver.Route
+	SectionsIPCRoute ipcserver.Route
 }
 
-func newStatus(deps dependencies) provides {
+func newStatus(deps dependencies) (provides, error) {
 	s := &status{
 		sections: providedRegistrations(deps.Registrations),
+		log:      deps.Log,
 	}
-	return provides{
-		Component: s,
-		FlareReg:  flare.FileRegistration("agent-status.json", s.flareFile),
-		IPCRoute:  ipcserver.NewRoute("/agent/status", s.ipcHandler),
+	sort.Sort(byOrder(s.sections))
+
+	seen := map[string]struct{}{}
+	for _, r := range s.sections {
+		if _, found := seen[r.section]; found {
+			return provides{}, fmt.Errorf("Status section %s is registered more than once", r.section)
+		}
+		seen[r.section] = struct{}{}
 	}
+
+	return provides{
+		Component:        s,
+		FlareReg:         flare.FileRegistration("agent-status.json", s.jsonFlareFile),
+		TextFlareReg:     flare.FileRegistration("agent-status.txt", s.textFlareFile),
+		IPCRoute:         ipcserver.NewRoute("/agent/status", "Agent status", []string{http.MethodGet}, s.ipcHandler),
+		SectionsIPCRoute: ipcserver.NewRoute("/agent/status/sections", "List status sections", []string{http.MethodGet}, s.sectionsIPCHandler),
+	}, nil
 }
 
 // providedRegistrations translates a slice of non-nil registrations
@@ -62,41 +89,255 @@ func providedRegistrations(registrations []registration) []registration {
 	return provided
 }
 
+// ListSections implements Component#ListSections.
+func (s *status) ListSections() []SectionInfo {
+	infos := make([]SectionInfo, 0, len(s.sections))
+	for _, r := range s.sections {
+		infos = append(infos, SectionInfo{Name: r.section, Description: r.description})
+	}
+	return infos
+}
+
+// selectSections returns the registrations for the named sections, in order,
+// or all registrations if names is empty.
+func (s *status) selectSections(names []string) ([]registration, error) {
+	if len(names) == 0 {
+		return s.sections, nil
+	}
+
+	wanted := map[string]struct{}{}
+	for _, name := range names {
+		wanted[name] = struct{}{}
+	}
+
+	selected := []registration{}
+	for _, r := range s.sections {
+		if _, found := wanted[r.section]; found {
+			selected = append(selected, r)
+			delete(wanted, r.section)
+		}
+	}
+
+	// anything left in wanted was not found; report the first in the order given
+	for _, name := range names {
+		if _, found := wanted[name]; found {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownSection, name)
+		}
+	}
+
+	return selected, nil
+}
+
+// collectedSection is a section's status, along with its text rendering.
+type collectedSection struct {
+	Section
+	Text string
+}
+
+// collectSections gets the status of the selected sections concurrently, also
+// rendering them as text if renderText is true.  The result is in the same order
+// as the selected registrations.
+func (s *status) collectSections(ctx context.Context, sections []string, renderText bool) ([]collectedSection, error) {
+	selected, err := s.selectSections(sections)
+	if err != nil {
+		return nil, err
+	}
+
+	collected := make([]collectedSection, len(selected))
+	var wg sync.WaitGroup
+	for i, r := range selected {
+		i, r := i, r
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			collected[i].Section = s.getSection(ctx, r)
+			if renderText {
+				collected[i].Text = r.renderText(collected[i].Section)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return collected, nil
+}
+
+// getSection calls the registration's callback to get its status.  The
+// callback runs in its own goroutine with a context that is cancelled after
+// sectionTimeout.  If the callback panics or does not return before that
+// context is done, the returned Section contains an error.  A callback which
+// does not return is abandoned.
+func (s *status) getSection(ctx context.Context, r registration) Section {
+	ctx, cancel := context.WithTimeout(ctx, sectionTimeout)
+	defer cancel()
+
+	// buffered so that an abandoned goroutine can still complete
+	result := make(chan Section, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				s.log.Error(fmt.Sprintf("Status section %s panicked: %v", r.section, p))
+				s.log.Debug(fmt.Sprintf("Status section %s panic stack:\n%s", r.section, debug.Stack()))
+				result <- Section{Name: r.section, Error: fmt.Sprintf("panic: %v", p)}
+			}
+		}()
+
+		data, err := r.cb(ctx)
+		if err != nil {
+			result <- Section{Name: r.section, Error: err.Error()}
+			return
+		}
+		result <- Section{Name: r.section, Data: data}
+	}()
+
+	select {
+	case section := <-result:
+		return section
+	case <-ctx.Done():
+		return Section{Name: r.section, Error: fmt.Sprintf("timed out: %s", ctx.Err())}
+	}
+}
+
 // GetStatus implements Component#GetStatus.
-func (s *status) GetStatus(section string) string {
-	s.Lock()
-	defer s.Unlock()
+func (s *status) GetStatus(ctx context.Context, sections []string) ([]Section, error) {
+	collected, err := s.collectSections(ctx, sections, false)
+	if err != nil {
+		return nil, err
+	}
+
+	rv := make([]Section, 0, len(collected))
+	for _, c := range collected {
+		rv = append(rv, c.Section)
+	}
+	return rv, nil
+}
+
+// Render implements Component#Render.
+func (s *status) Render(ctx context.Context, sections []string, format string) (string, error) {
+	switch format {
+	case TextFormat:
+		return s.renderText(ctx, sections)
+	case JSONFormat:
+		return s.renderJSON(ctx, sections)
+	case HTMLFormat:
+		return s.renderHTML(ctx, sections)
+	default:
+		return "", fmt.Errorf("Unsupported status format %q", format)
+	}
+}
+
+// renderText renders the status as text.
+func (s *status) renderText(ctx context.Context, sections []string) (string, error) {
+	collected, err := s.collectSections(ctx, sections, true)
+	if err != nil {
+		return "", err
+	}
 
 	var bldr strings.Builder
-	for _, s := range s.sections {
-		if section != "" && s.section != section {
-			continue
-		}
+	for _, c := range collected {
+		fmt.Fprintf(&bldr, "%s\n", c.Text)
+	}
+
+	return bldr.String() + "\n", nil
+}
 
-		fmt.Fprintf(&bldr, "%s\n", s.cb())
+// renderJSON renders the status as JSON.
+func (s *status) renderJSON(ctx context.Context, sections []string) (string, error) {
+	status, err := s.GetStatus(ctx, sections)
+	if err != nil {
+		return "", err
 	}
 
-	if bldr.Len() == 0 {
-		fmt.Fprintf(&bldr, "Status section %s is not defined", section)
+	content, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(content) + "\n", nil
+}
+
+var htmlTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Agent Status</title>
+</head>
+<body>
+{{- range . }}
+<div class="section" id="{{ .Name }}">
+<pre>{{ .Text }}</pre>
+</div>
+{{- end }}
+</body>
+</html>
+`))
+
+// renderHTML renders the status as an HTML page.
+func (s *status) renderHTML(ctx context.Context, sections []string) (string, error) {
+	collected, err := s.collectSections(ctx, sections, true)
+	if err != nil {
+		return "", err
 	}
 
-	return bldr.String() + "\n"
+	var bldr strings.Builder
+	err = htmlTemplate.Execute(&bldr, collected)
+	if err != nil {
+		return "", err
+	}
+	return bldr.String(), nil
 }
 
-// ipcHandler serves the /agent/status endpoint
+// ipcHandler serves the /agent/status endpoint.  The `format` query parameter
+// selects the format, defaulting to text, and the `section` query parameter,
+// which may be repeated, selects sections.  For the JSON format, this returns
+// {"sections": [..]}, and otherwise {"status": <rendered status>}.  Errors are
+// returned as {"error": <message>}, with a 404 for unknown sections.
 func (s *status) ipcHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header()["Content-Type"] = []string{"application/json; charset=UTF-8"}
 
-	var section string
-	sections, ok := r.URL.Query()["section"]
-	if ok && len(sections) == 1 {
-		section = sections[0]
+	query := r.URL.Query()
+	sections := query["section"]
+	format := query.Get("format")
+	if format == "" {
+		format = TextFormat
+	}
+
+	var content interface{}
+	var err error
+	if format == JSONFormat {
+		var status []Section
+		status, err = s.GetStatus(r.Context(), sections)
+		content = map[string][]Section{"sections": status}
+	} else {
+		var rendered string
+		rendered, err = s.Render(r.Context(), sections, format)
+		content = map[string]string{"status": rendered}
 	}
 
-	json.NewEncoder(w).Encode(map[string]string{"status": s.GetStatus(section)})
+	if err != nil {
+		if errors.Is(err, ErrUnknownSection) {
+			w.WriteHeader(404)
+		} else {
+			w.WriteHeader(400)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(content)
+}
+
+// sectionsIPCHandler serves the /agent/status/sections endpoint, returning
+// {"sections": [{"name": .., "description": ..}, ..]}.
+func (s *status) sectionsIPCHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header()["Content-Type"] = []string{"application/json; charset=UTF-8"}
+	json.NewEncoder(w).Encode(map[string][]SectionInfo{"sections": s.ListSections()})
+}
+
+// jsonFlareFile creates the agent-status.json file for flares.
+func (s *status) jsonFlareFile() (string, error) {
+	return s.renderJSON(context.Background(), nil)
 }
 
-// flareFile creates the agent-status.txt file for flares.
-func (s *status) flareFile() (string, error) {
-	return s.GetStatus(""), nil
+// textFlareFile creates the agent-status.txt file for flares.
+func (s *status) textFlareFile() (string, error) {
+	return s.renderText(context.Background(), nil)
 }
@@ -0,0 +1,91 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package subscriptionmon implements a component that monitors the delivery of
+// messages over subscriptions (pkg/util/subscriptions).
+//
+// Every subscription created with subscriptions.NewSubscription carries an
+// *subscriptions.Info in value-group "subscriptions", which this component
+// collects.  Nil *subscriptions.Info values are ignored, assuming they are for
+// disabled components.
+//
+// Each subscription is registered with comp/core/health as a non-critical
+// component named "<subscriber> subscription to <message type>".  The
+// subscription is reported as Degraded while its receiver is dropping messages,
+// or while its queue exceeds its high-water mark, and Healthy otherwise.  A
+// warning is logged each time a queue rises above its high-water mark.
+// Delivery statistics for all subscriptions appear in the "subscriptions"
+// status section.
+//
+// The component also provides a *subscriptions.Registry, with which
+// subscriptions.Publisher registers each transmitter it creates.  Together
+// with the subscriptions, this gives the topology of messages between
+// components: which components publish which message types, to which
+// subscribers, along with per-edge message counts and queue depths.  The
+// topology appears in the "subscriptions" status section, and in flares as
+// subscriptions.json and as subscriptions.dot (for Graphviz).  It highlights
+// publications with no subscribers and subscriptions with no publishers,
+// which usually indicate a component that is disabled.  Receivers subscribed
+// at runtime without WithSubscriber are shown as "(unnamed)".
+//
+// All of the component's methods can be called concurrently.
+package subscriptionmon
+
+import (
+	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/subscriptions"
+	"go.uber.org/fx"
+)
+
+// team: agent-shared-components
+
+const componentName = "comp/core/subscriptionmon"
+
+// Component is the component type.
+type Component interface {
+	// GetSubscriptions gets the current delivery statistics for all
+	// subscriptions, sorted by subscriber and then by message type.
+	GetSubscriptions() []SubscriptionStats
+
+	// GetTopology gets the current topology of subscriptions between
+	// components.
+	GetTopology() Topology
+}
+
+// Subscription identifies a subscription.
+type Subscription struct {
+	// Subscriber is the name of the subscribing component.
+	Subscriber string `json:"subscriber"`
+
+	// MessageType is the type of message delivered over the subscription.
+	MessageType string `json:"message_type"`
+}
+
+// SubscriptionStats contains the delivery statistics for a single
+// subscription.
+type SubscriptionStats struct {
+	Subscription
+	subscriptions.Stats
+}
+
+// Topology describes the subscriptions between components.
+type Topology struct {
+	// Edges are the connections from publishers to subscribers, sorted by
+	// publisher, message type, and then subscriber.
+	Edges []subscriptions.Edge `json:"edges"`
+
+	// Unsubscribed are the publications that have no subscribers.
+	Unsubscribed []subscriptions.Publication `json:"unsubscribed"`
+
+	// Unpublished are the subscriptions for message types that no registered
+	// component publishes.
+	Unpublished []Subscription `json:"unpublished"`
+}
+
+// Module defines the fx options for this component.
+var Module = fx.Module(
+	componentName,
+	fx.Provide(newMonitor),
+	fx.Provide(subscriptions.NewRegistry),
+)
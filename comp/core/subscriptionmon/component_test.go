@@ -0,0 +1,164 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package subscriptionmon
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/config"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/health"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/internal"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/log"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/status"
+	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/comptest"
+	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/subscriptions"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+)
+
+const (
+	droppingName = "comp/dropping subscription to string"
+	queueingName = "comp/queueing subscription to int"
+)
+
+func TestHealth(t *testing.T) {
+	dropping := subscriptions.NewSubscription[string]("comp/dropping",
+		subscriptions.WithPolicy(subscriptions.DropNewest))
+	queueing := subscriptions.NewSubscription[int]("comp/queueing",
+		subscriptions.WithPolicy(subscriptions.Unbounded),
+		subscriptions.WithHighWaterMark(3))
+
+	var mon Component
+	var h health.Component
+	var l log.Component
+	comptest.FxTest(t,
+		Module,
+		health.Module,
+		log.MockModule,
+		config.MockModule,
+		fx.Supply(internal.BundleParams{}),
+		fx.Provide(func() subscriptions.Subscription[string] { return dropping }),
+		fx.Provide(func() subscriptions.Subscription[int] { return queueing }),
+		// a disabled component's subscription is ignored
+		fx.Provide(func() subscriptions.Subscription[bool] { return subscriptions.Subscription[bool]{} }),
+		fx.Populate(&mon),
+		fx.Populate(&h),
+		fx.Populate(&l),
+	).WithRunningApp(func() {
+		m := mon.(*monitor)
+		require.Len(t, m.subs, 2)
+
+		getHealth := func(name string) health.ComponentHealth {
+			return h.GetHealth()[name]
+		}
+		require.Equal(t, health.ComponentHealth{State: health.Ready, Status: health.Healthy}, getHealth(droppingName))
+		require.Equal(t, health.ComponentHealth{State: health.Ready, Status: health.Healthy}, getHealth(queueingName))
+
+		// nobody is reading, so the second message is dropped
+		strTx := subscriptions.NewTransmitter([]subscriptions.Receiver[string]{dropping.Receiver})
+		strTx.Notify("one")
+		strTx.Notify("two")
+
+		// and messages queue up beyond the high-water mark
+		intTx := subscriptions.NewTransmitter([]subscriptions.Receiver[int]{queueing.Receiver})
+		for i := 0; i < 5; i++ {
+			intTx.Notify(i)
+		}
+
+		l.(log.Mock).StartCapture()
+		m.check()
+		require.Contains(t, l.(log.Mock).Captured(),
+			queueingName+" exceeded its high-water mark of 3 queued messages (peak 5, now 5)\n")
+		l.(log.Mock).EndCapture()
+		require.Equal(t, health.Degraded, getHealth(droppingName).Status)
+		require.Contains(t, getHealth(droppingName).Message, "dropped 1 messages")
+		require.Equal(t, health.Degraded, getHealth(queueingName).Status)
+		require.Equal(t, "5 messages queued, above high-water mark of 3", getHealth(queueingName).Message)
+
+		// once the receivers catch up, they are healthy again
+		<-dropping.Receiver.Chan()
+		for i := 0; i < 5; i++ {
+			require.Equal(t, i, <-queueing.Receiver.Chan())
+		}
+		m.check()
+		require.Equal(t, health.Healthy, getHealth(droppingName).Status)
+		require.Equal(t, health.Healthy, getHealth(queueingName).Status)
+	})
+}
+
+func TestStatus(t *testing.T) {
+	sub := subscriptions.NewSubscription[string]("comp/thing",
+		subscriptions.WithPolicy(subscriptions.DropOldest))
+
+	var st status.Component
+	comptest.FxTest(t,
+		Module,
+		status.Module,
+		log.MockModule,
+		fx.Provide(func() subscriptions.Subscription[string] { return sub }),
+		fx.Populate(&st),
+	).WithRunningApp(func() {
+		tx := subscriptions.NewTransmitter([]subscriptions.Receiver[string]{sub.Receiver})
+		tx.Notify("one")
+		tx.Notify("two")
+
+		text, err := st.Render(context.Background(), []string{"subscriptions"}, status.TextFormat)
+		require.NoError(t, err)
+		require.Contains(t, text, "comp/thing <- string\n  Policy: drop-oldest\n  Delivered: 2\n  Dropped: 1\n  Queued: 1\n")
+	})
+}
+
+func TestTopology(t *testing.T) {
+	var mon Component
+	var st status.Component
+	var tx subscriptions.Transmitter[string]
+	comptest.FxTest(t,
+		Module,
+		status.Module,
+		log.MockModule,
+		fx.Provide(func() subscriptions.Subscription[string] {
+			return subscriptions.NewSubscription[string]("comp/rx", subscriptions.WithPolicy(subscriptions.Unbounded))
+		}),
+		fx.Provide(func() subscriptions.Subscription[bool] {
+			return subscriptions.NewSubscription[bool]("comp/orphan")
+		}),
+		fx.Invoke(func(pub subscriptions.Publisher[string]) { tx = pub.Transmitter("comp/tx") }),
+		fx.Invoke(func(pub subscriptions.Publisher[int]) { pub.Transmitter("comp/lonely") }),
+		fx.Populate(&mon),
+		fx.Populate(&st),
+	).WithRunningApp(func() {
+		tx.Notify("one")
+		tx.Notify("two")
+		_, err := tx.Subscribe()
+		require.NoError(t, err)
+
+		strPub := subscriptions.Publication{Publisher: "comp/tx", MessageType: "string"}
+		require.Equal(t, Topology{
+			Edges: []subscriptions.Edge{
+				{Publication: strPub, Subscriber: "", Sent: 0, Queued: 0},
+				{Publication: strPub, Subscriber: "comp/rx", Sent: 2, Queued: 2},
+			},
+			Unsubscribed: []subscriptions.Publication{{Publisher: "comp/lonely", MessageType: "int"}},
+			Unpublished:  []Subscription{{Subscriber: "comp/orphan", MessageType: "bool"}},
+		}, mon.GetTopology())
+
+		dot, err := mon.(*monitor).flareDOT()
+		require.NoError(t, err)
+		require.Contains(t, dot, `"comp/tx" -> "comp/rx" [label="string\nsent 2, queued 2"];`)
+		require.Contains(t, dot, `"comp/tx" -> "(unnamed)"`)
+		require.Contains(t, dot, `"comp/lonely" -> "int\n(no subscribers)" [style=dashed];`)
+		require.Contains(t, dot, `"bool\n(no publishers)" -> "comp/orphan" [style=dashed];`)
+
+		text, err := st.Render(context.Background(), []string{"subscriptions"}, status.TextFormat)
+		require.NoError(t, err)
+		require.Contains(t, text, "Topology:\n"+
+			"  comp/tx -> (unnamed) (string): sent 0, queued 0\n"+
+			"  comp/tx -> comp/rx (string): sent 2, queued 2\n"+
+			"  comp/lonely -> (no subscribers) (int)\n"+
+			"  (no publishers) -> comp/orphan (bool)\n")
+	})
+}
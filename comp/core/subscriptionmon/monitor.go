@@ -0,0 +1,231 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package subscriptionmon
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/flare"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/health"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/log"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/status"
+	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/subscriptions"
+	"go.uber.org/fx"
+)
+
+// checkInterval is the interval between checks of subscription health.
+const checkInterval = 10 * time.Second
+
+type monitor struct {
+	log log.Component
+
+	// registry records the transmitters created by publishers
+	registry *subscriptions.Registry
+
+	// subs are the monitored subscriptions, sorted by subscriber and message
+	// type.  This is not modified after construction.
+	subs []*subscription
+
+	// stop is closed to stop the monitoring goroutine
+	stop chan struct{}
+
+	// stopped is closed when the monitoring goroutine has stopped
+	stopped chan struct{}
+}
+
+// subscription is the monitor's record of a single subscription.
+type subscription struct {
+	info   *subscriptions.Info
+	handle *health.Handle
+
+	// lastDropped and lastCrossings are the values of Stats.Dropped and
+	// Stats.HighWaterCrossings at the previous check.  They are only accessed
+	// from the monitoring goroutine.
+	lastDropped   uint64
+	lastCrossings uint64
+}
+
+type dependencies struct {
+	fx.In
+
+	Lc       fx.Lifecycle
+	Log      log.Component
+	Registry *subscriptions.Registry
+
+	Infos []*subscriptions.Info `group:"subscriptions"`
+}
+
+type provides struct {
+	fx.Out
+
+	Component
+	HealthHandles []*health.Handle `group:"health,flatten"`
+	StatusReg     status.Registration
+	JSONFlareReg  flare.Registration
+	DOTFlareReg   flare.Registration
+}
+
+func newMonitor(deps dependencies) provides {
+	m := &monitor{
+		log:      deps.Log,
+		registry: deps.Registry,
+	}
+
+	// filter out nil Infos, from disabled components
+	for _, info := range deps.Infos {
+		if info != nil {
+			m.subs = append(m.subs, &subscription{info: info})
+		}
+	}
+	sort.Slice(m.subs, func(i, j int) bool {
+		a, b := m.subs[i].info, m.subs[j].info
+		if a.Subscriber != b.Subscriber {
+			return a.Subscriber < b.Subscriber
+		}
+		return a.MessageType < b.MessageType
+	})
+
+	handles := make([]*health.Handle, 0, len(m.subs))
+	for _, sub := range m.subs {
+		name := fmt.Sprintf("%s subscription to %s", sub.info.Subscriber, sub.info.MessageType)
+		sub.handle = health.NewRegistration(name).Handle
+		sub.handle.HookLifecycle(deps.Lc)
+		handles = append(handles, sub.handle)
+	}
+
+	// this component cannot use pkg/util/actor, whose tests depend on the
+	// core bundle, so it manages its goroutine directly
+	deps.Lc.Append(fx.Hook{OnStart: m.start, OnStop: m.stopMonitor})
+
+	return provides{
+		Component:     m,
+		HealthHandles: handles,
+		StatusReg:     status.NewRegistration("subscriptions", "Subscriptions between components, with delivery statistics", 10, m.status, statusTemplate),
+		JSONFlareReg:  flare.FileRegistration("subscriptions.json", m.flareJSON),
+		DOTFlareReg:   flare.FileRegistration("subscriptions.dot", m.flareDOT),
+	}
+}
+
+// GetSubscriptions implements Component#GetSubscriptions.
+func (m *monitor) GetSubscriptions() []SubscriptionStats {
+	rv := make([]SubscriptionStats, 0, len(m.subs))
+	for _, sub := range m.subs {
+		rv = append(rv, SubscriptionStats{
+			Subscription: Subscription{
+				Subscriber:  sub.info.Subscriber,
+				MessageType: sub.info.MessageType,
+			},
+			Stats: sub.info.Stats(),
+		})
+	}
+	return rv
+}
+
+// start marks all subscriptions as ready, as there is nothing for them to wait
+// for, and starts the monitoring goroutine.
+func (m *monitor) start(context.Context) error {
+	for _, sub := range m.subs {
+		sub.handle.SetReady()
+	}
+	m.stop = make(chan struct{})
+	m.stopped = make(chan struct{})
+	go m.run()
+	return nil
+}
+
+// stopMonitor stops the monitoring goroutine, waiting until it is complete or
+// the context is cancelled.
+func (m *monitor) stopMonitor(ctx context.Context) error {
+	close(m.stop)
+	select {
+	case <-m.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run checks subscription health periodically, until stopped.
+func (m *monitor) run() {
+	defer close(m.stopped)
+	tkr := time.NewTicker(checkInterval)
+	defer tkr.Stop()
+
+	for {
+		select {
+		case <-tkr.C:
+			m.check()
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// check updates the health of each subscription, and warns of subscriptions
+// whose queue has risen above its high-water mark.
+func (m *monitor) check() {
+	for _, sub := range m.subs {
+		stats := sub.info.Stats()
+		dropped := stats.Dropped - sub.lastDropped
+		sub.lastDropped = stats.Dropped
+		crossings := stats.HighWaterCrossings - sub.lastCrossings
+		sub.lastCrossings = stats.HighWaterCrossings
+
+		if crossings > 0 {
+			m.log.Warn(fmt.Sprintf("%s subscription to %s exceeded its high-water mark of %d queued messages (peak %d, now %d)",
+				sub.info.Subscriber, sub.info.MessageType, stats.HighWaterMark, stats.PeakQueued, stats.Queued))
+		}
+
+		switch {
+		case dropped > 0:
+			sub.handle.SetDegraded(fmt.Sprintf("dropped %d messages in the last %s (policy %s)", dropped, checkInterval, stats.Policy))
+		case stats.HighWaterMark > 0 && stats.Queued > stats.HighWaterMark:
+			sub.handle.SetDegraded(fmt.Sprintf("%d messages queued, above high-water mark of %d", stats.Queued, stats.HighWaterMark))
+		default:
+			sub.handle.SetHealthy()
+		}
+	}
+}
+
+// statusData is the data for the subscriptions status section.
+type statusData struct {
+	Subscriptions []SubscriptionStats `json:"subscriptions"`
+	Topology      Topology            `json:"topology"`
+}
+
+const statusTemplate = `=============
+Subscriptions
+=============
+{{ range .Subscriptions }}
+{{ .Subscriber }} <- {{ .MessageType }}
+  Policy: {{ .Policy }}
+  Delivered: {{ .Delivered }}
+  Dropped: {{ .Dropped }}
+  Queued: {{ .Queued }}
+{{- if .HighWaterMark }} (peak {{ .PeakQueued }}, high-water mark {{ .HighWaterMark }}){{ end }}
+{{- end }}
+
+Topology:
+{{- range .Topology.Edges }}
+  {{ .Publisher }} -> {{ or .Subscriber "(unnamed)" }} ({{ .MessageType }}): sent {{ .Sent }}, queued {{ .Queued }}
+{{- end }}
+{{- range .Topology.Unsubscribed }}
+  {{ .Publisher }} -> (no subscribers) ({{ .MessageType }})
+{{- end }}
+{{- range .Topology.Unpublished }}
+  (no publishers) -> {{ .Subscriber }} ({{ .MessageType }})
+{{- end }}
+`
+
+func (m *monitor) status(context.Context) (any, error) {
+	return statusData{
+		Subscriptions: m.GetSubscriptions(),
+		Topology:      m.GetTopology(),
+	}, nil
+}
@@ -0,0 +1,96 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package subscriptionmon
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/subscriptions"
+)
+
+// unnamedSubscriber is shown in place of the name of a receiver created
+// without one.
+const unnamedSubscriber = "(unnamed)"
+
+// GetTopology implements Component#GetTopology.
+func (m *monitor) GetTopology() Topology {
+	topo := Topology{
+		Edges:        m.registry.Edges(),
+		Unsubscribed: []subscriptions.Publication{},
+		Unpublished:  []Subscription{},
+	}
+
+	// find publications with no edges, and message types with edges
+	connected := map[subscriptions.Publication]struct{}{}
+	published := map[string]struct{}{}
+	for _, edge := range topo.Edges {
+		connected[edge.Publication] = struct{}{}
+		published[edge.MessageType] = struct{}{}
+	}
+	for _, pub := range m.registry.Publications() {
+		published[pub.MessageType] = struct{}{}
+		if _, found := connected[pub]; !found {
+			topo.Unsubscribed = append(topo.Unsubscribed, pub)
+		}
+	}
+
+	for _, sub := range m.subs {
+		if _, found := published[sub.info.MessageType]; !found {
+			topo.Unpublished = append(topo.Unpublished, Subscription{
+				Subscriber:  sub.info.Subscriber,
+				MessageType: sub.info.MessageType,
+			})
+		}
+	}
+
+	return topo
+}
+
+// flareJSON creates the subscriptions.json flare file.
+func (m *monitor) flareJSON() (string, error) {
+	content, err := json.MarshalIndent(m.GetTopology(), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// flareDOT creates the subscriptions.dot flare file, describing the topology
+// in the Graphviz DOT language.  Publications without subscribers, and
+// subscriptions without publishers, are shown as dashed edges to or from a
+// placeholder node.
+func (m *monitor) flareDOT() (string, error) {
+	topo := m.GetTopology()
+
+	var bldr strings.Builder
+	bldr.WriteString("digraph subscriptions {\n")
+	bldr.WriteString("  rankdir=LR;\n")
+	bldr.WriteString("  node [shape=box];\n")
+	for _, edge := range topo.Edges {
+		subscriber := edge.Subscriber
+		if subscriber == "" {
+			subscriber = unnamedSubscriber
+		}
+		label := fmt.Sprintf("%s\nsent %d, queued %d", edge.MessageType, edge.Sent, edge.Queued)
+		fmt.Fprintf(&bldr, "  %s -> %s [label=%s];\n",
+			strconv.Quote(edge.Publisher), strconv.Quote(subscriber), strconv.Quote(label))
+	}
+	for _, pub := range topo.Unsubscribed {
+		placeholder := strconv.Quote(fmt.Sprintf("%s\n(no subscribers)", pub.MessageType))
+		fmt.Fprintf(&bldr, "  %s [shape=plaintext];\n", placeholder)
+		fmt.Fprintf(&bldr, "  %s -> %s [style=dashed];\n", strconv.Quote(pub.Publisher), placeholder)
+	}
+	for _, sub := range topo.Unpublished {
+		placeholder := strconv.Quote(fmt.Sprintf("%s\n(no publishers)", sub.MessageType))
+		fmt.Fprintf(&bldr, "  %s [shape=plaintext];\n", placeholder)
+		fmt.Fprintf(&bldr, "  %s -> %s [style=dashed];\n", placeholder, strconv.Quote(sub.Subscriber))
+	}
+	bldr.WriteString("}\n")
+	return bldr.String(), nil
+}
@@ -0,0 +1,114 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package telemetry implements a component that collects internal metrics
+// about the agent itself, such as payloads received or queue depths.
+//
+// Other components depend on this component and create metrics in their
+// constructors, storing the result for later use.  Metrics are named
+// `<subsystem>_<name>`, and each metric name may only be created once.  Metrics
+// may have tags, given as a list of tag names when the metric is created; each
+// update to the metric must then supply exactly one value for each tag, in the
+// same order.
+//
+// Four kinds of metrics are supported: counters, which only increase; gauges,
+// which can be set to any value; gauge functions, which call a function to get
+// the current value (useful for, for example, the length of a channel); and
+// histograms, which count observed values in a set of buckets.
+//
+// Counters and histograms can also be read back, for components that report
+// their own metrics elsewhere, such as in status output.
+//
+// The metrics are available in the Prometheus text exposition format via the
+// IPC API at `/metrics`, served by the ipcserver component, and are included in
+// flares as `telemetry.txt` by the flare component.  This component depends on
+// no other components, so that any component can create metrics.
+//
+// All of the component's methods, and all methods of the metrics it creates,
+// can be called concurrently.
+package telemetry
+
+import (
+	"io"
+
+	"go.uber.org/fx"
+)
+
+// team: agent-shared-components
+
+const componentName = "comp/core/telemetry"
+
+// Component is the component type.
+type Component interface {
+	// NewCounter creates a new counter.  This panics if a metric with the same
+	// name already exists.
+	NewCounter(subsystem, name string, tags []string, help string) Counter
+
+	// NewGauge creates a new gauge.  This panics if a metric with the same
+	// name already exists.
+	NewGauge(subsystem, name string, tags []string, help string) Gauge
+
+	// NewGaugeFunc creates a new untagged gauge whose value is determined by
+	// calling `fn` each time metrics are collected.  The function may be
+	// called concurrently with any other activity, and must return quickly.
+	// This panics if a metric with the same name already exists.
+	NewGaugeFunc(subsystem, name string, help string, fn func() float64)
+
+	// NewHistogram creates a new histogram with the given bucket upper
+	// bounds, which must be sorted in increasing order.  If buckets is nil,
+	// DefaultBuckets is used.  This panics if a metric with the same name
+	// already exists.
+	NewHistogram(subsystem, name string, tags []string, help string, buckets []float64) Histogram
+
+	// WriteText writes all metrics to w, in the Prometheus text exposition
+	// format.  Metrics are sorted by name, and series by tag values.
+	WriteText(w io.Writer) error
+}
+
+// Counter is a metric whose value only increases.
+type Counter interface {
+	// Inc increments the counter by 1.
+	Inc(tagValues ...string)
+
+	// Add adds the given value, which must not be negative, to the counter.
+	Add(value float64, tagValues ...string)
+
+	// Value gets the counter's current value.
+	Value(tagValues ...string) float64
+}
+
+// Gauge is a metric whose value can be set arbitrarily.
+type Gauge interface {
+	// Set sets the gauge to the given value.
+	Set(value float64, tagValues ...string)
+
+	// Inc increments the gauge by 1.
+	Inc(tagValues ...string)
+
+	// Dec decrements the gauge by 1.
+	Dec(tagValues ...string)
+
+	// Add adds the given value, which may be negative, to the gauge.
+	Add(value float64, tagValues ...string)
+}
+
+// Histogram is a metric that counts observations in buckets.
+type Histogram interface {
+	// Observe records an observation of the given value.
+	Observe(value float64, tagValues ...string)
+
+	// Summary gets the number of observations and their sum.
+	Summary(tagValues ...string) (count uint64, sum float64)
+}
+
+// DefaultBuckets are the default histogram buckets, suitable for durations in
+// seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Module defines the fx options for this component.
+var Module fx.Option = fx.Module(
+	componentName,
+	fx.Provide(newTelemetry),
+)
@@ -0,0 +1,110 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package telemetry
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/comptest"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+)
+
+func TestMetrics(t *testing.T) {
+	var comp Component
+	comptest.FxTest(t,
+		Module,
+		fx.Populate(&comp),
+	).WithRunningApp(func() {
+		counter := comp.NewCounter("test", "payloads", []string{"endpoint"}, "Payloads received")
+		gauge := comp.NewGauge("test", "workers", nil, "Active workers")
+		depth := 3
+		comp.NewGaugeFunc("test", "queue_depth", "Queue depth\nin payloads", func() float64 { return float64(depth) })
+		hist := comp.NewHistogram("test", "latency", nil, "Latency", []float64{0.1, 1})
+
+		counter.Inc("v0.4")
+		counter.Add(2, "v0.4")
+		counter.Inc(`we"ird`)
+		gauge.Set(5)
+		gauge.Dec()
+		hist.Observe(0.05)
+		hist.Observe(0.5)
+		hist.Observe(10)
+
+		var bldr strings.Builder
+		require.NoError(t, comp.WriteText(&bldr))
+		require.Equal(t, strings.Join([]string{
+			"# HELP test_latency Latency",
+			"# TYPE test_latency histogram",
+			`test_latency_bucket{le="0.1"} 1`,
+			`test_latency_bucket{le="1"} 2`,
+			`test_latency_bucket{le="+Inf"} 3`,
+			"test_latency_sum 10.55",
+			"test_latency_count 3",
+			"# HELP test_payloads Payloads received",
+			"# TYPE test_payloads counter",
+			`test_payloads{endpoint="v0.4"} 3`,
+			`test_payloads{endpoint="we\"ird"} 1`,
+			`# HELP test_queue_depth Queue depth\nin payloads`,
+			"# TYPE test_queue_depth gauge",
+			"test_queue_depth 3",
+			"# HELP test_workers Active workers",
+			"# TYPE test_workers gauge",
+			"test_workers 4",
+			"",
+		}, "\n"), bldr.String())
+	})
+}
+
+func TestMisuse(t *testing.T) {
+	var comp Component
+	comptest.FxTest(t,
+		Module,
+		fx.Populate(&comp),
+	).WithRunningApp(func() {
+		counter := comp.NewCounter("test", "things", []string{"kind"}, "Things")
+		require.Panics(t, func() { comp.NewGauge("test", "things", nil, "Duplicate") })
+		require.Panics(t, func() { counter.Inc() })
+		require.Panics(t, func() { counter.Add(-1, "kind") })
+		require.Panics(t, func() { comp.NewHistogram("test", "unsorted", nil, "Unsorted", []float64{2, 1}) })
+	})
+}
+
+func TestRead(t *testing.T) {
+	var comp Component
+	comptest.FxTest(t,
+		Module,
+		fx.Populate(&comp),
+	).WithRunningApp(func() {
+		counter := comp.NewCounter("test", "things", []string{"kind"}, "Things")
+		hist := comp.NewHistogram("test", "latency", []string{"kind"}, "Latency", nil)
+
+		require.Equal(t, float64(0), counter.Value("a"))
+		count, sum := hist.Summary("a")
+		require.Equal(t, uint64(0), count)
+		require.Equal(t, float64(0), sum)
+
+		counter.Add(3, "a")
+		counter.Inc("b")
+		hist.Observe(0.5, "a")
+		hist.Observe(1.5, "a")
+
+		require.Equal(t, float64(3), counter.Value("a"))
+		require.Equal(t, float64(1), counter.Value("b"))
+		count, sum = hist.Summary("a")
+		require.Equal(t, uint64(2), count)
+		require.Equal(t, float64(2), sum)
+		require.Panics(t, func() { counter.Value() })
+
+		// reading does not create series
+		counter.Value("c")
+		hist.Summary("c")
+		var bldr strings.Builder
+		require.NoError(t, comp.WriteText(&bldr))
+		require.NotContains(t, bldr.String(), `kind="c"`)
+	})
+}
@@ -0,0 +1,305 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package telemetry
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// metric is implemented by all metric types.
+type metric interface {
+	// desc returns the metric's description
+	desc() *desc
+
+	// write writes the metric's samples in the Prometheus text format
+	write(w io.Writer) error
+}
+
+// desc describes a metric.
+type desc struct {
+	// name is the full name of the metric, `<subsystem>_<name>`
+	name string
+
+	// tags are the tag names
+	tags []string
+
+	// help is the help text for the metric
+	help string
+
+	// kind is the Prometheus metric type
+	kind string
+}
+
+func newDesc(subsystem, name string, tags []string, help, kind string) *desc {
+	return &desc{
+		name: subsystem + "_" + name,
+		tags: append([]string{}, tags...),
+		help: help,
+		kind: kind,
+	}
+}
+
+// labels formats the given tag values as Prometheus labels, including the
+// braces, with any extra label appended.  It returns an empty string if there
+// are no labels.
+func (d *desc) labels(tagValues []string, extra ...string) string {
+	pairs := []string{}
+	for i, tag := range d.tags {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, tag, escapeLabel(tagValues[i])))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extra[i], escapeLabel(extra[i+1])))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// series holds the per-tag-value state of a metric.  The type parameter is the
+// state of a single series.
+type series[T any] struct {
+	// Mutex covers values and the content of each value
+	sync.Mutex
+
+	d *desc
+
+	// values contains the state for each combination of tag values, keyed by
+	// the tag values joined with a NUL character
+	values map[string]*seriesValue[T]
+}
+
+type seriesValue[T any] struct {
+	tagValues []string
+	state     T
+}
+
+func newSeries[T any](d *desc) series[T] {
+	return series[T]{d: d, values: map[string]*seriesValue[T]{}}
+}
+
+func (s *series[T]) desc() *desc {
+	return s.d
+}
+
+// update calls fn with the state for the given tag values, with the series
+// locked.  It panics if the wrong number of tag values is given.
+func (s *series[T]) update(tagValues []string, fn func(state *T)) {
+	s.checkTags(tagValues)
+
+	s.Lock()
+	defer s.Unlock()
+
+	key := strings.Join(tagValues, "\x00")
+	v, found := s.values[key]
+	if !found {
+		v = &seriesValue[T]{tagValues: append([]string{}, tagValues...)}
+		s.values[key] = v
+	}
+	fn(&v.state)
+}
+
+// get gets the state for the given tag values, or the zero state if nothing
+// has been recorded for them.  It panics if the wrong number of tag values is
+// given.
+func (s *series[T]) get(tagValues []string) T {
+	s.checkTags(tagValues)
+
+	s.Lock()
+	defer s.Unlock()
+
+	var state T
+	if v, found := s.values[strings.Join(tagValues, "\x00")]; found {
+		state = v.state
+	}
+	return state
+}
+
+// checkTags panics if the wrong number of tag values is given.
+func (s *series[T]) checkTags(tagValues []string) {
+	if len(tagValues) != len(s.d.tags) {
+		panic(fmt.Sprintf("telemetry metric %s expects %d tag values, got %d", s.d.name, len(s.d.tags), len(tagValues)))
+	}
+}
+
+// each calls fn for each series, sorted by tag values, with the series locked,
+// stopping at the first error.
+func (s *series[T]) each(fn func(tagValues []string, state *T) error) error {
+	s.Lock()
+	defer s.Unlock()
+
+	keys := make([]string, 0, len(s.values))
+	for k := range s.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := s.values[k]
+		if err := fn(v.tagValues, &v.state); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// counter implements Counter.
+type counter struct {
+	series[float64]
+}
+
+func (c *counter) Inc(tagValues ...string) {
+	c.Add(1, tagValues...)
+}
+
+func (c *counter) Add(value float64, tagValues ...string) {
+	if value < 0 {
+		panic(fmt.Sprintf("telemetry counter %s cannot decrease", c.d.name))
+	}
+	c.update(tagValues, func(state *float64) { *state += value })
+}
+
+func (c *counter) Value(tagValues ...string) float64 {
+	return c.get(tagValues)
+}
+
+func (c *counter) write(w io.Writer) error {
+	return c.each(func(tagValues []string, state *float64) error {
+		_, err := fmt.Fprintf(w, "%s%s %s\n", c.d.name, c.d.labels(tagValues), formatFloat(*state))
+		return err
+	})
+}
+
+// gauge implements Gauge.
+type gauge struct {
+	series[float64]
+}
+
+func (g *gauge) Set(value float64, tagValues ...string) {
+	g.update(tagValues, func(state *float64) { *state = value })
+}
+
+func (g *gauge) Inc(tagValues ...string) {
+	g.Add(1, tagValues...)
+}
+
+func (g *gauge) Dec(tagValues ...string) {
+	g.Add(-1, tagValues...)
+}
+
+func (g *gauge) Add(value float64, tagValues ...string) {
+	g.update(tagValues, func(state *float64) { *state += value })
+}
+
+func (g *gauge) write(w io.Writer) error {
+	return g.each(func(tagValues []string, state *float64) error {
+		_, err := fmt.Fprintf(w, "%s%s %s\n", g.d.name, g.d.labels(tagValues), formatFloat(*state))
+		return err
+	})
+}
+
+// gaugeFunc is a gauge whose value is supplied by a function.
+type gaugeFunc struct {
+	d  *desc
+	fn func() float64
+}
+
+func (g *gaugeFunc) desc() *desc {
+	return g.d
+}
+
+func (g *gaugeFunc) write(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "%s %s\n", g.d.name, formatFloat(g.fn()))
+	return err
+}
+
+// histogram implements Histogram.
+type histogram struct {
+	series[histogramState]
+
+	// buckets are the upper bounds of the buckets, not including +Inf
+	buckets []float64
+}
+
+type histogramState struct {
+	// counts are the non-cumulative counts in each bucket, with the last
+	// element counting values greater than all buckets.
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) Observe(value float64, tagValues ...string) {
+	h.update(tagValues, func(state *histogramState) {
+		if state.counts == nil {
+			state.counts = make([]uint64, len(h.buckets)+1)
+		}
+		state.counts[sort.SearchFloat64s(h.buckets, value)]++
+		state.sum += value
+		state.count++
+	})
+}
+
+func (h *histogram) Summary(tagValues ...string) (uint64, float64) {
+	state := h.get(tagValues)
+	return state.count, state.sum
+}
+
+func (h *histogram) write(w io.Writer) error {
+	return h.each(func(tagValues []string, state *histogramState) error {
+		var cumulative uint64
+		for i, count := range state.counts {
+			cumulative += count
+			le := math.Inf(1)
+			if i < len(h.buckets) {
+				le = h.buckets[i]
+			}
+			_, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.d.name, h.d.labels(tagValues, "le", formatFloat(le)), cumulative)
+			if err != nil {
+				return err
+			}
+		}
+		_, err := fmt.Fprintf(w, "%s_sum%s %s\n%s_count%s %d\n",
+			h.d.name, h.d.labels(tagValues), formatFloat(state.sum),
+			h.d.name, h.d.labels(tagValues), state.count)
+		return err
+	})
+}
+
+// formatFloat formats a float as Prometheus expects.
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+// escapeHelp escapes help text for the Prometheus text format.
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+// escapeLabel escapes a label value for the Prometheus text format.
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
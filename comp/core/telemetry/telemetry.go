@@ -0,0 +1,101 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package telemetry
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+type telemetry struct {
+	// Mutex covers metrics
+	sync.Mutex
+
+	// metrics contains all metrics, keyed by full name
+	metrics map[string]metric
+}
+
+func newTelemetry() Component {
+	return &telemetry{
+		metrics: map[string]metric{},
+	}
+}
+
+// register adds a new metric, panicking if the name is already in use.
+func (t *telemetry) register(m metric) {
+	t.Lock()
+	defer t.Unlock()
+
+	name := m.desc().name
+	if _, found := t.metrics[name]; found {
+		panic(fmt.Sprintf("telemetry metric %s is registered more than once", name))
+	}
+	t.metrics[name] = m
+}
+
+// NewCounter implements Component#NewCounter.
+func (t *telemetry) NewCounter(subsystem, name string, tags []string, help string) Counter {
+	c := &counter{series: newSeries[float64](newDesc(subsystem, name, tags, help, "counter"))}
+	t.register(c)
+	return c
+}
+
+// NewGauge implements Component#NewGauge.
+func (t *telemetry) NewGauge(subsystem, name string, tags []string, help string) Gauge {
+	g := &gauge{series: newSeries[float64](newDesc(subsystem, name, tags, help, "gauge"))}
+	t.register(g)
+	return g
+}
+
+// NewGaugeFunc implements Component#NewGaugeFunc.
+func (t *telemetry) NewGaugeFunc(subsystem, name string, help string, fn func() float64) {
+	t.register(&gaugeFunc{d: newDesc(subsystem, name, nil, help, "gauge"), fn: fn})
+}
+
+// NewHistogram implements Component#NewHistogram.
+func (t *telemetry) NewHistogram(subsystem, name string, tags []string, help string, buckets []float64) Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("telemetry histogram %s_%s buckets are not sorted", subsystem, name))
+	}
+	h := &histogram{
+		series:  newSeries[histogramState](newDesc(subsystem, name, tags, help, "histogram")),
+		buckets: append([]float64{}, buckets...),
+	}
+	t.register(h)
+	return h
+}
+
+// WriteText implements Component#WriteText.
+func (t *telemetry) WriteText(w io.Writer) error {
+	t.Lock()
+	metrics := make([]metric, 0, len(t.metrics))
+	for _, m := range t.metrics {
+		metrics = append(metrics, m)
+	}
+	t.Unlock()
+
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].desc().name < metrics[j].desc().name
+	})
+
+	for _, m := range metrics {
+		d := m.desc()
+		_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, escapeHelp(d.help), d.name, d.kind)
+		if err != nil {
+			return err
+		}
+		err = m.write(w)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -7,8 +7,7 @@ package agent
 
 import (
 	"context"
-	"fmt"
-	"strings"
+	"sort"
 
 	"go.uber.org/fx"
 
@@ -43,7 +42,7 @@ func newAgent(deps dependencies) (Component, status.Registration) {
 	var reg status.Registration
 	if deps.Params.ShouldStart(deps.Config) {
 		deps.Lc.Append(fx.Hook{OnStart: a.start, OnStop: a.stop})
-		reg = status.NewRegistration("logs-agent", 4, a.status)
+		reg = status.NewRegistration("logs-agent", "Logs agent status, including running launchers", 4, a.status, statusTemplate)
 	}
 
 	return a, reg
@@ -59,18 +58,26 @@ func (a *agent) stop(context.Context) error {
 	return nil
 }
 
-func (a *agent) status() string {
-	var bldr strings.Builder
+// statusData is the data for the logs-agent status section.
+type statusData struct {
+	Launchers []string `json:"launchers"`
+}
+
+const statusTemplate = `==========
+Logs Agent
+==========
 
-	fmt.Fprintf(&bldr, "==========\n")
-	fmt.Fprintf(&bldr, "Logs Agent\n")
-	fmt.Fprintf(&bldr, "==========\n")
-	fmt.Fprintf(&bldr, "\n")
-	fmt.Fprintf(&bldr, "Running Launchers:\n")
+Running Launchers:
+{{- range .Launchers }}
+ {{ . }}
+{{- end }}
+`
 
+func (a *agent) status(context.Context) (any, error) {
+	data := statusData{Launchers: []string{}}
 	for name := range a.launchermgr.GetLaunchers() {
-		fmt.Fprintf(&bldr, " %s\n", name)
+		data.Launchers = append(data.Launchers, name)
 	}
-
-	return bldr.String()
+	sort.Strings(data.Launchers)
+	return data, nil
 }
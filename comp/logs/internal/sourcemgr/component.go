@@ -9,7 +9,9 @@
 // and removals.
 //
 // To subscribe to these changes, provide a
-// subscriptions.Subscription[sourcemgr.SourceChange].
+// subscriptions.Subscription[sourcemgr.SourceChange].  Components that start
+// receiving changes only at runtime, such as dynamically started launchers, can
+// call Subscribe and Unsubscribe instead.
 //
 // Once added to this component, a LogSource must be considered immutable: neither
 // the component having called AddSource, nor any of the subscribers, may modify the
@@ -19,6 +21,7 @@
 package sourcemgr
 
 import (
+	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/subscriptions"
 	"go.uber.org/fx"
 )
 
@@ -28,11 +31,22 @@ const componentName = "comp/logs/internal/sourcemgr"
 
 // Component is the component type.
 type Component interface {
-	// AddSource adds a new log source.
+	// AddSource adds a new log source.  Adding a source that is already
+	// present does nothing.
 	AddSource(*LogSource)
 
-	// RemoveSource removes an existing log source.
+	// RemoveSource removes an existing log source.  Removing a source that is
+	// not present does nothing.
 	RemoveSource(*LogSource)
+
+	// Subscribe subscribes to SourceChanges, with the given receiver options.
+	// The receiver first gets a SourceChange adding each current source,
+	// followed by later changes.  The subscriber must call Unsubscribe when it
+	// no longer reads from the receiver.
+	Subscribe(opts ...subscriptions.Option) (subscriptions.Receiver[SourceChange], error)
+
+	// Unsubscribe ends a subscription made with Subscribe.
+	Unsubscribe(subscriptions.Receiver[SourceChange])
 }
 
 // LogSource defines a source for logs.
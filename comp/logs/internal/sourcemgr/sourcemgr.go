@@ -13,6 +13,7 @@ import (
 	"github.com/DataDog/dd-agent-comp-experiments/comp/autodiscovery/scheduler"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/config"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/health"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/log"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/logs/internal"
 	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/actor"
 	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/subscriptions"
@@ -26,8 +27,9 @@ type sourceMgr struct {
 	// started is true once the component has started
 	started bool
 
-	// sourceChangeTx is used to send SourceChanges
-	sourceChangeTx subscriptions.Transmitter[SourceChange]
+	// sourceChangeTx is used to send SourceChanges, and tracks the current
+	// sources
+	sourceChangeTx *subscriptions.StateTransmitter[*LogSource, *LogSource, SourceChange]
 
 	// configChangeRx is used to subscribe to AD ConfigChanges
 	configChangeRx subscriptions.Receiver[scheduler.ConfigChange]
@@ -39,21 +41,27 @@ type dependencies struct {
 	Lc     fx.Lifecycle
 	Params internal.BundleParams
 	Config config.Component
+	Log    log.Component
 	Pub    subscriptions.Publisher[SourceChange]
 }
 
 func newSourceMgr(deps dependencies) (Component, subscriptions.Subscription[scheduler.ConfigChange]) {
-	healthReg := health.NewRegistration(componentName)
+	healthReg := health.NewCriticalRegistration(componentName)
 	sm := &sourceMgr{
-		sourceChangeTx: deps.Pub.Transmitter(),
+		sourceChangeTx: subscriptions.NewStateTransmitter(deps.Pub.Transmitter(componentName),
+			func(src *LogSource) *LogSource { return src },
+			func(src *LogSource, isAdd bool) SourceChange { return SourceChange{IsAdd: isAdd, Source: src} }),
 	}
 	var sub subscriptions.Subscription[scheduler.ConfigChange]
 	if deps.Params.ShouldStart(deps.Config) {
 		actor := actor.New()
+		actor.LogFailures(deps.Log)
 		actor.HookLifecycle(deps.Lc, sm.run)
 		actor.MonitorLiveness(healthReg.Handle, time.Second)
 		deps.Lc.Append(fx.Hook{OnStart: sm.start})
-		sub = subscriptions.NewSubscription[scheduler.ConfigChange]()
+		// queue config changes rather than stalling autodiscovery
+		sub = subscriptions.NewSubscription[scheduler.ConfigChange](componentName,
+			subscriptions.WithPolicy(subscriptions.Unbounded))
 		sm.configChangeRx = sub.Receiver
 	}
 	return sm, sub
@@ -67,7 +75,7 @@ func (sm *sourceMgr) start(ctx context.Context) error {
 	return nil
 }
 
-func (sm *sourceMgr) run(ctx context.Context, alive <-chan struct{}) {
+func (sm *sourceMgr) run(ctx context.Context, alive <-chan struct{}) error {
 	sources := map[string]*LogSource{}
 	for {
 		select {
@@ -87,7 +95,7 @@ func (sm *sourceMgr) run(ctx context.Context, alive <-chan struct{}) {
 			}
 		case <-alive:
 		case <-ctx.Done():
-			return
+			return nil
 		}
 	}
 }
@@ -99,10 +107,7 @@ func (sm *sourceMgr) AddSource(source *LogSource) {
 	if !sm.started {
 		panic("sourcemgr component has not been started")
 	}
-	sm.sourceChangeTx.Notify(SourceChange{
-		IsAdd:  true,
-		Source: source,
-	})
+	sm.sourceChangeTx.Add(source)
 }
 
 // RemoveSource implements Component#RemoveSource.
@@ -112,8 +117,15 @@ func (sm *sourceMgr) RemoveSource(source *LogSource) {
 	if !sm.started {
 		panic("sourcemgr component has not been started")
 	}
-	sm.sourceChangeTx.Notify(SourceChange{
-		IsAdd:  false,
-		Source: source,
-	})
+	sm.sourceChangeTx.Remove(source)
+}
+
+// Subscribe implements Component#Subscribe.
+func (sm *sourceMgr) Subscribe(opts ...subscriptions.Option) (subscriptions.Receiver[SourceChange], error) {
+	return sm.sourceChangeTx.Subscribe(opts...)
+}
+
+// Unsubscribe implements Component#Unsubscribe.
+func (sm *sourceMgr) Unsubscribe(rx subscriptions.Receiver[SourceChange]) {
+	sm.sourceChangeTx.Unsubscribe(rx)
 }
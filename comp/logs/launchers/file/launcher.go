@@ -51,9 +51,12 @@ func newLauncher(deps dependencies) provides {
 	var sub subscriptions.Subscription[sourcemgr.SourceChange]
 	if deps.Params.ShouldStart(deps.Config) {
 		actor := actor.New()
+		actor.LogFailures(deps.Log)
 		actor.HookLifecycle(deps.Lc, l.run)
 		actor.MonitorLiveness(healthReg.Handle, time.Second)
-		sub = subscriptions.NewSubscription[sourcemgr.SourceChange]()
+		// queue source changes rather than stalling sourcemgr
+		sub = subscriptions.NewSubscription[sourcemgr.SourceChange](componentName,
+			subscriptions.WithPolicy(subscriptions.Unbounded))
 		l.sourceChangeRx = sub.Receiver
 	}
 	return provides{
@@ -64,7 +67,7 @@ func newLauncher(deps dependencies) provides {
 	}
 }
 
-func (l *launcher) run(ctx context.Context, alive <-chan struct{}) {
+func (l *launcher) run(ctx context.Context, alive <-chan struct{}) error {
 	for {
 		select {
 		case chg := <-l.sourceChangeRx.Chan():
@@ -72,7 +75,7 @@ func (l *launcher) run(ctx context.Context, alive <-chan struct{}) {
 			// XXX start a tailer, etc. etc.
 		case <-alive:
 		case <-ctx.Done():
-			return
+			return nil
 		}
 	}
 }
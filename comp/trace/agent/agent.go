@@ -8,8 +8,6 @@ package agent
 
 import (
 	"context"
-	"fmt"
-	"strings"
 
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/config"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/log"
@@ -43,7 +41,7 @@ func newAgent(deps dependencies) (Component, status.Registration) {
 	var reg status.Registration
 	if deps.Params.ShouldStart(deps.Config) {
 		deps.Lc.Append(fx.Hook{OnStart: a.start, OnStop: a.stop})
-		reg = status.NewRegistration("trace-agent", 3, a.status)
+		reg = status.NewRegistration("trace-agent", "Trace agent status", 3, a.status, statusTemplate)
 	}
 
 	return a, reg
@@ -59,14 +57,18 @@ func (a *agent) stop(context.Context) error {
 	return nil
 }
 
-func (a *agent) status() string {
-	var bldr strings.Builder
+// statusData is the data for the trace-agent status section.
+type statusData struct {
+	Status string `json:"status"`
+}
+
+const statusTemplate = `===========
+Trace Agent
+===========
 
-	fmt.Fprintf(&bldr, "===========\n")
-	fmt.Fprintf(&bldr, "Trace Agent\n")
-	fmt.Fprintf(&bldr, "===========\n")
-	fmt.Fprintf(&bldr, "\n")
-	fmt.Fprintf(&bldr, "STATUS: Doin' just fine, thanks!\n")
+STATUS: {{ .Status }}
+`
 
-	return bldr.String()
+func (a *agent) status(context.Context) (any, error) {
+	return statusData{Status: "Doin' just fine, thanks!"}, nil
 }
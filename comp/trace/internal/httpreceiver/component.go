@@ -5,6 +5,12 @@
 
 // Package httpreceiver listens for incoming spans via HTTP and submits them to
 // the APM agent pipeline.
+//
+// The receiver listens on 127.0.0.1:`apm_config.receiver_port`, binding during
+// startup so that failures such as port conflicts fail startup.  If
+// `apm_config.receiver_start_failure_unhealthy` is true, such failures are
+// instead logged and mark the receiver unhealthy, leaving the rest of the
+// agent running.  If the port is 0, a free port is chosen and logged.
 package httpreceiver
 
 import (
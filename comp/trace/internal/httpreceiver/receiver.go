@@ -8,25 +8,51 @@ package httpreceiver
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/config"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/health"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/log"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/telemetry"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/trace/internal"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/trace/internal/processor"
 	"github.com/DataDog/dd-agent-comp-experiments/pkg/trace/api"
+	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/pipe"
 	"go.uber.org/fx"
 )
 
 type receiver struct {
-	// port is the port on which the server is running.
+	// port is the port on which the server is running.  If zero, the
+	// server chooses a free port.
 	port int
 
+	// unhealthyOnStartFailure is true if a failure to start the server should
+	// mark the receiver unhealthy, instead of failing startup.
+	unhealthyOnStartFailure bool
+
+	// health is the receiver's health handle
+	health *health.Handle
+
 	// server is the running server
 	server *http.Server
 
-	// processorChan is the channel to the processor component
-	processorChan chan<- *api.Payload
+	// processorPipe is the pipe to the processor component
+	processorPipe pipe.Sender[*api.Payload]
+
+	// received counts payloads received
+	received telemetry.Counter
+
+	// dropped counts payloads dropped, tagged by reason
+	dropped telemetry.Counter
+
+	// payloadSpans measures the number of spans in each payload
+	payloadSpans telemetry.Histogram
+
+	// log is the log component
+	log log.Component
 }
 
 type dependencies struct {
@@ -35,27 +61,52 @@ type dependencies struct {
 	Lc        fx.Lifecycle
 	Params    internal.BundleParams
 	Config    config.Component
+	Log       log.Component
+	Telemetry telemetry.Component
 	Processor processor.Component
 }
 
-func newReceiver(deps dependencies) Component {
+func newReceiver(deps dependencies) (Component, health.Registration) {
+	healthReg := health.NewCriticalRegistration(componentName)
 	r := &receiver{
-		port:          deps.Config.GetInt("apm_config.receiver_port"),
-		processorChan: deps.Processor.PayloadChan(),
+		port:                    deps.Config.GetInt("apm_config.receiver_port"),
+		unhealthyOnStartFailure: deps.Config.GetBool("apm_config.receiver_start_failure_unhealthy"),
+		health:                  healthReg.Handle,
+		processorPipe:           deps.Processor.PayloadPipe(),
+		received:                deps.Telemetry.NewCounter("trace_receiver", "payloads_received", nil, "Payloads received"),
+		dropped:                 deps.Telemetry.NewCounter("trace_receiver", "payloads_dropped", []string{"reason"}, "Payloads dropped"),
+		payloadSpans: deps.Telemetry.NewHistogram("trace_receiver", "payload_spans", nil, "Spans per payload",
+			[]float64{1, 10, 100, 1000}),
+		log: deps.Log,
 	}
 	if deps.Params.ShouldStart(deps.Config) {
+		healthReg.Handle.HookLifecycle(deps.Lc)
 		deps.Lc.Append(fx.Hook{OnStart: r.start, OnStop: r.stop})
 	}
-	return r
+	return r, healthReg
 }
 
-// start starts the http server.
+// start starts the http server.  The listener is bound before this returns,
+// so failures such as port conflicts fail startup, unless
+// unhealthyOnStartFailure is set.
 func (r *receiver) start(ctx context.Context) error {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", r.port))
+	if err != nil {
+		err = fmt.Errorf("Could not start trace receiver: %w", err)
+		if !r.unhealthyOnStartFailure {
+			return err
+		}
+		r.log.Error(err.Error())
+		r.health.SetUnhealthy(err.Error())
+		return nil
+	}
+
 	r.server = &http.Server{
-		Addr:    fmt.Sprintf("127.0.0.1:%d", r.port),
 		Handler: http.HandlerFunc(r.handler),
 	}
-	go r.server.ListenAndServe()
+	go r.server.Serve(listener)
+	r.log.Info(fmt.Sprintf("Trace receiver listening on http://%s", listener.Addr()))
+	r.health.SetReady()
 	return nil
 }
 
@@ -73,11 +124,24 @@ func (r *receiver) handler(w http.ResponseWriter, req *http.Request) {
 	for scanner.Scan() {
 		spans = append(spans, api.Span{Data: scanner.Text()})
 	}
+	r.received.Inc()
 	if err := scanner.Err(); err != nil {
+		r.dropped.Inc("invalid")
 		w.WriteHeader(400)
+		return
 	}
+	r.payloadSpans.Observe(float64(len(spans)))
 
-	r.processorChan <- &api.Payload{Spans: spans}
+	err := r.processorPipe.Send(req.Context(), &api.Payload{Spans: spans})
+	switch {
+	case errors.Is(err, pipe.ErrClosed):
+		// the agent is shutting down, so the client should retry elsewhere
+		r.dropped.Inc("closed")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	case err != nil:
+		// the client gave up while waiting for the processor
+		r.dropped.Inc("canceled")
+	}
 }
 
 // stop stops the http server.
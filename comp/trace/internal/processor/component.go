@@ -10,6 +10,7 @@ package processor
 
 import (
 	"github.com/DataDog/dd-agent-comp-experiments/pkg/trace/api"
+	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/pipe"
 	"go.uber.org/fx"
 )
 
@@ -19,8 +20,8 @@ const componentName = "comp/trace/internal/processor"
 
 // Component is the component type.
 type Component interface {
-	// PayloadChan returns the channel to which receiver components should direct Payloads.
-	PayloadChan() chan<- *api.Payload
+	// PayloadPipe returns the pipe to which receiver components should direct Payloads.
+	PayloadPipe() pipe.Sender[*api.Payload]
 }
 
 // Module defines the fx options for this component.
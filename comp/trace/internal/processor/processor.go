@@ -13,24 +13,24 @@ import (
 
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/config"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/health"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/log"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/telemetry"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/trace/internal"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/trace/internal/tracewriter"
 	"github.com/DataDog/dd-agent-comp-experiments/pkg/trace/api"
-	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/actor"
+	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/chanworkers"
+	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/pipe"
 	"go.uber.org/fx"
 )
 
 // processor implements the singleton controlling the workers.
-//
-// TODO: actually use workers (perhaps with some general support in pkg/util/chanworkers)
 type processor struct {
-	// payloadChan is the channel where this component gets the payloads
+	// payloadPipe is the pipe where this component gets the payloads
 	// to process
-	payloadChan chan *api.Payload
+	payloadPipe *pipe.Pipe[*api.Payload]
 
-	// traceWriterChan is the channel to which this component writes payloads
-	// that should be sent to the Datadog API.
-	traceWriterChan chan<- *api.Payload
+	// processed counts payloads processed
+	processed telemetry.Counter
 }
 
 type dependencies struct {
@@ -39,43 +39,44 @@ type dependencies struct {
 	Lc          fx.Lifecycle
 	Params      internal.BundleParams
 	Config      config.Component
+	Log         log.Component
+	Telemetry   telemetry.Component
 	TraceWriter tracewriter.Component
 }
 
 func newProcessor(deps dependencies) (Component, health.Registration) {
 	width := runtime.NumCPU()
-	healthReg := health.NewRegistration(componentName)
+	healthReg := health.NewCriticalRegistration(componentName)
 	p := &processor{
-		payloadChan:     make(chan *api.Payload, width),
-		traceWriterChan: deps.TraceWriter.PayloadChan(),
+		payloadPipe: pipe.New[*api.Payload](width, pipe.WithTelemetry(deps.Telemetry, "trace_processor")),
+		processed:   deps.Telemetry.NewCounter("trace_processor", "payloads_processed", nil, "Payloads processed"),
 	}
 	if deps.Params.ShouldStart(deps.Config) {
-		actor := actor.New()
-		actor.HookLifecycle(deps.Lc, p.run)
-		actor.MonitorLiveness(healthReg.Handle, time.Second)
+		// processed payloads go directly to the tracewriter
+		workers := chanworkers.New(p.payloadPipe.Chan(), deps.TraceWriter.PayloadPipe(), p.process,
+			chanworkers.WithWorkers(width))
+		workers.LogFailures(deps.Log)
+		workers.HookLifecycle(deps.Lc)
+		workers.MonitorLiveness(healthReg.Handle, time.Second)
+		// stop accepting payloads before the workers drain those already queued
+		deps.Lc.Append(fx.Hook{OnStop: func(context.Context) error {
+			p.payloadPipe.Close()
+			return nil
+		}})
 	}
 	return p, healthReg
 }
 
-func (p *processor) PayloadChan() chan<- *api.Payload {
-	return p.payloadChan
+func (p *processor) PayloadPipe() pipe.Sender[*api.Payload] {
+	return p.payloadPipe
 }
 
-// run implements the component's core loop
-func (p *processor) run(ctx context.Context, alive <-chan struct{}) {
-	for {
-		select {
-		case payload := <-p.payloadChan:
-			// XXX there's lots of processing to do here, but it all occurs in
-			// the same goroutine via function calls.  That could be done in
-			// this component (as it's done in pkg/trace now), or by calling
-			// methods on other components.  Using other components would
-			// facilitate testing, but otherwise not add a lot of value.
-
-			p.traceWriterChan <- payload
-		case <-alive:
-		case <-ctx.Done():
-			return
-		}
-	}
+// process processes a single payload, in one of the component's workers.
+func (p *processor) process(ctx context.Context, payload *api.Payload) *api.Payload {
+	// XXX there's lots of processing to do here, all via function calls.  That
+	// could be done in this component (as it's done in pkg/trace now), or by
+	// calling methods on other components.  Using other components would
+	// facilitate testing, but otherwise not add a lot of value.
+	p.processed.Inc()
+	return payload
 }
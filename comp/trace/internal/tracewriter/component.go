@@ -9,6 +9,7 @@ package tracewriter
 
 import (
 	"github.com/DataDog/dd-agent-comp-experiments/pkg/trace/api"
+	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/pipe"
 	"go.uber.org/fx"
 )
 
@@ -18,9 +19,9 @@ const componentName = "comp/trace/internal/tracewriter"
 
 // Component is the component type.
 type Component interface {
-	// PayloadChan returns the channel to which components should direct
+	// PayloadPipe returns the pipe to which components should direct
 	// Payloads to be written.
-	PayloadChan() chan<- *api.Payload
+	PayloadPipe() pipe.Sender[*api.Payload]
 }
 
 // Module defines the fx options for this component.
@@ -12,53 +12,91 @@ import (
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/config"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/health"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/log"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/telemetry"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/trace/internal"
 	"github.com/DataDog/dd-agent-comp-experiments/pkg/trace/api"
 	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/actor"
+	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/pipe"
 	"go.uber.org/fx"
 )
 
 type traceWriter struct {
-	in chan *api.Payload
+	// in is the pipe where this component gets the payloads to write
+	in *pipe.Pipe[*api.Payload]
 
 	log log.Component
+
+	// written counts payloads written
+	written telemetry.Counter
 }
 
+// maxBatch is the largest number of payloads this component writes at once.
+const maxBatch = 16
+
 type dependencies struct {
 	fx.In
 
-	Lc     fx.Lifecycle
-	Params internal.BundleParams
-	Config config.Component
-	Log    log.Component
+	Lc        fx.Lifecycle
+	Params    internal.BundleParams
+	Config    config.Component
+	Log       log.Component
+	Telemetry telemetry.Component
 }
 
 func newTraceWriter(deps dependencies) (Component, health.Registration) {
-	healthReg := health.NewRegistration(componentName)
+	healthReg := health.NewCriticalRegistration(componentName)
 	t := &traceWriter{
-		in:  make(chan *api.Payload, 1000),
-		log: deps.Log,
+		in:      pipe.New[*api.Payload](1000, pipe.WithTelemetry(deps.Telemetry, "trace_tracewriter")),
+		log:     deps.Log,
+		written: deps.Telemetry.NewCounter("trace_tracewriter", "payloads_written", nil, "Payloads written"),
 	}
 	if deps.Params.ShouldStart(deps.Config) {
 		actor := actor.New()
+		actor.LogFailures(deps.Log)
+		actor.DrainOnStop()
 		actor.HookLifecycle(deps.Lc, t.run)
 		actor.MonitorLiveness(healthReg.Handle, time.Second)
+		// a slow writer backs up the whole trace pipeline
+		actor.DegradeOnLatency(100 * time.Millisecond)
 	}
 	return t, healthReg
 }
 
-func (t *traceWriter) PayloadChan() chan<- *api.Payload {
+func (t *traceWriter) PayloadPipe() pipe.Sender[*api.Payload] {
 	return t.in
 }
 
-func (t *traceWriter) run(ctx context.Context, alive <-chan struct{}) {
+func (t *traceWriter) run(ctx context.Context, alive <-chan struct{}) error {
+	draining := actor.Draining(ctx)
 	for {
 		select {
-		case payload := <-t.in:
-			t.log.Debug("sending payload", payload)
+		case payload := <-t.in.Chan():
+			t.write(t.in.Batch(payload, maxBatch))
 		case <-alive:
+		case <-draining:
+			// stop accepting payloads, and write those already queued
+			t.in.Close()
+			for {
+				select {
+				case payload := <-t.in.Chan():
+					t.write(t.in.Batch(payload, maxBatch))
+				case <-alive:
+				case <-ctx.Done():
+					return nil
+				default:
+					return nil
+				}
+			}
 		case <-ctx.Done():
-			return
+			return nil
 		}
 	}
 }
+
+// write writes a batch of payloads.
+func (t *traceWriter) write(payloads []*api.Payload) {
+	for _, payload := range payloads {
+		t.log.Debug("sending payload", payload)
+		t.written.Inc()
+	}
+}
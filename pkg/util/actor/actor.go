@@ -8,19 +8,33 @@
 // Methods on this component are not re-entrant.  Components using this one
 // should _either_ call HookLifecycle once in their constructor or call Start
 // and Stop from their lifecycle hook.
+//
+// An actor can be stopped and started again, with Restart or with a later call
+// to Start, and can restart itself automatically when its run function fails
+// (see RestartOnFailure).
+//
+// The run function fails if it returns an error, returns before the actor is
+// stopped, or panics.  Panics are recovered, so a failing actor does not
+// crash the agent.  Failures are reported to the health handle given to
+// MonitorLiveness and logged to the logger given to LogFailures, and an actor
+// that does not restart can shut down the app (see ShutdownOnFailure).
 package actor
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
 	"time"
 
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/health"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/log"
 	"go.uber.org/fx"
 )
 
 // Actor manages a component structured as an actor, supporting starting and
-// later stopping the goroutine.  This is one-shot: once started and stopped,
-// the goroutine cannot be started again.
+// later stopping the goroutine.  Once stopped, the actor can be started again.
 type Actor struct {
 	// healthHandle is the handle to which liveness data should be reported.  If
 	// this is nil, liveness is not monitored.
@@ -31,16 +45,44 @@ type Actor struct {
 	// during this time.
 	livenessPeriod time.Duration
 
-	// started is true after the goroutine has been started, and remains true after
-	// it has stopped.
-	started bool
+	// degradedLatency is the threshold passed to DegradeOnLatency, or zero.
+	degradedLatency time.Duration
+
+	// backoff is the backoff passed to RestartOnFailure, or nil if the actor
+	// should not restart automatically.
+	backoff *Backoff
+
+	// log is the logger passed to LogFailures, or nil if failures should not
+	// be logged.
+	log log.Component
+
+	// shutdowner is the shutdowner passed to ShutdownOnFailure, or nil if
+	// failures should not shut down the app.
+	shutdowner fx.Shutdowner
+
+	// drainOnStop is true if DrainOnStop has been called.
+	drainOnStop bool
+
+	// runFunc is the function most recently passed to Start.
+	runFunc RunFunc
+
+	// running is true from Start until the following Stop.
+	running bool
 
 	// cancel cancels the context passed to the `run` function, used to signal
 	// that it should stop
 	cancel context.CancelFunc
 
+	// draining is closed when Stop is called, used to signal that the `run`
+	// function should drain its queued work.
+	draining chan struct{}
+
 	// stopped is closed once the run function returns.
 	stopped chan struct{}
+
+	// restarts counts restarts of the run function, and is accessed
+	// atomically.
+	restarts uint32
 }
 
 // New creates a new actor.
@@ -49,11 +91,79 @@ func New() *Actor {
 }
 
 // RunFunc defines the function implementing the actor's event loop.  It should
-// run until the passed context is cancelled.
+// run until the passed context is cancelled, or for actors that drain on stop
+// until it has drained its queued work, and then return nil.  Returning before
+// the actor is stopped, with or without an error, is a failure.
 //
 // The loop should read from `alive`, discarding the results.  This is used by
 // MonitorLiveness to monitor the component's health.
-type RunFunc func(ctx context.Context, alive <-chan struct{})
+type RunFunc func(ctx context.Context, alive <-chan struct{}) error
+
+// ErrReturned is the failure reported when a run function returns nil before
+// the actor is stopped.
+var ErrReturned = errors.New("run function returned before the actor was stopped")
+
+// PanicError is the failure reported when a run function panics.
+type PanicError struct {
+	// Value is the value passed to panic.
+	Value interface{}
+
+	// Stack is the stack trace of the panicking goroutine.
+	Stack string
+}
+
+// Error implements error#Error.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("run function panicked: %v", e.Value)
+}
+
+// Backoff configures the delay before an actor restarts automatically.
+//
+// The first restart waits for Initial, and each consecutive restart waits
+// twice as long as the last, up to Max.  A run that lasts at least Max before
+// failing is not considered consecutive, and resets the delay to Initial.
+type Backoff struct {
+	// Initial is the delay before the first restart.
+	Initial time.Duration
+
+	// Max is the largest delay between restarts.
+	Max time.Duration
+}
+
+// next returns the delay following the given delay.
+func (b *Backoff) next(delay time.Duration) time.Duration {
+	delay *= 2
+	if delay > b.Max {
+		delay = b.Max
+	}
+	return delay
+}
+
+// RestartOnFailure indicates that the actor should restart its run function,
+// after a delay determined by the given backoff, if it fails.
+//
+// While waiting to restart, the actor is reported as unhealthy to the handle
+// given to MonitorLiveness, if any, and each restart is recorded with that
+// handle.
+func (a *Actor) RestartOnFailure(backoff Backoff) {
+	if backoff.Max < backoff.Initial {
+		backoff.Max = backoff.Initial
+	}
+	a.backoff = &backoff
+}
+
+// LogFailures indicates that the actor should log failures of its run
+// function, including the stack trace of any panic, to the given logger.
+func (a *Actor) LogFailures(log log.Component) {
+	a.log = log
+}
+
+// ShutdownOnFailure indicates that the actor should shut down the app with the
+// given shutdowner if its run function fails and the actor is not configured
+// to restart.  Use this for actors without which the app cannot continue.
+func (a *Actor) ShutdownOnFailure(shutdowner fx.Shutdowner) {
+	a.shutdowner = shutdowner
+}
 
 // HookLifecycle connects this actor to the given fx.Lifecycle, starting and
 // stopping it with the lifecycle.  Use this method _or_ the Start and Stop methods,
@@ -69,45 +179,189 @@ func (a *Actor) HookLifecycle(lc fx.Lifecycle, runFunc RunFunc) {
 }
 
 // Start starts run in a goroutine, setting up to stop it by cancelling the context
-// it receives.
+// it receives (see also DrainOnStop).  An actor that has been stopped can be
+// started again.
 func (a *Actor) Start(runFunc RunFunc) {
-	if a.started {
-		panic("Goroutine has already been started")
+	if a.running {
+		panic("Goroutine is already running")
 	}
-	a.started = true
+	a.running = true
+	a.runFunc = runFunc
 
-	ctx, cancel := context.WithCancel(context.Background())
+	a.draining = make(chan struct{})
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), drainingKey{}, a.draining))
 	a.cancel = cancel
 	a.stopped = make(chan struct{})
 
-	go a.run(runFunc, ctx)
+	if a.healthHandle != nil {
+		a.healthHandle.SetStarting()
+	}
+
+	go a.run(ctx, runFunc, a.draining, a.stopped)
 }
 
 // Stop stops the goroutine, waiting until it is complete, or the given context
 // is cancelled, before returning.  Returns the error from context if it is
 // cancelled.
+//
+// If the actor drains on stop, the goroutine's context is only cancelled once
+// it has finished draining or the given context is cancelled.
+//
+// If Stop returns an error, the goroutine may still be running, and the actor
+// cannot be started again until a later call to Stop succeeds.
 func (a *Actor) Stop(ctx context.Context) error {
-	if !a.started {
-		panic("Goroutine has not been started")
+	if !a.running {
+		panic("Goroutine is not running")
 	}
-	if a.cancel == nil {
-		panic("Goroutine has already been stopped")
+
+	// begin stopping, unless a previous call to Stop has done so
+	if cancel := a.cancel; cancel != nil {
+		close(a.draining)
+		if !a.drainOnStop {
+			cancel()
+		}
 	}
-	a.cancel()
-	a.cancel = nil
+
 	select {
 	case <-a.stopped:
-		return nil
 	case <-ctx.Done():
+		a.cancelRun()
 		return ctx.Err()
 	}
+
+	a.cancelRun()
+	a.running = false
+	if a.healthHandle != nil {
+		a.healthHandle.SetStopped()
+	}
+	return nil
 }
 
-// run executes the given function, ensuring that the stopped channel is closed
-// when it finishes.  This method runs in a dedicated goroutine.
-func (a *Actor) run(runFunc RunFunc, ctx context.Context) {
-	defer close(a.stopped)
+// cancelRun cancels the goroutine's context, if not already cancelled.
+func (a *Actor) cancelRun() {
+	if a.cancel != nil {
+		a.cancel()
+		a.cancel = nil
+	}
+}
+
+// Restart stops the goroutine and starts it again with the same run function
+// and a fresh context.  If the goroutine does not stop before the given
+// context is cancelled, this returns the error from the context without
+// restarting.
+func (a *Actor) Restart(ctx context.Context) error {
+	runFunc := a.runFunc
+	if err := a.Stop(ctx); err != nil {
+		return err
+	}
+	a.Start(runFunc)
+	a.recordRestart()
+	return nil
+}
+
+// Restarts returns the number of times the actor has been restarted, whether
+// by Restart or automatically.
+func (a *Actor) Restarts() int {
+	return int(atomic.LoadUint32(&a.restarts))
+}
+
+// recordRestart counts a restart and reports it to the health handle.
+func (a *Actor) recordRestart() {
+	atomic.AddUint32(&a.restarts, 1)
+	if a.healthHandle != nil {
+		a.healthHandle.RecordRestart()
+	}
+}
+
+// run executes the given run function, handling failures and restarting it
+// if configured to do so, and ensures that the stopped channel is closed when
+// it finishes.  This method runs in a dedicated goroutine.
+func (a *Actor) run(ctx context.Context, runFunc RunFunc, draining, stopped chan struct{}) {
+	defer close(stopped)
+
+	var delay time.Duration
+	if a.backoff != nil {
+		delay = a.backoff.Initial
+	}
+	for {
+		begin := time.Now()
+		err := a.runOnce(ctx, runFunc)
+		if isClosed(draining) {
+			// the actor is stopping, so only an unexpected error is a failure
+			if err != nil && !errors.Is(err, context.Canceled) {
+				a.logFailure(err)
+			}
+			return
+		}
+		if err == nil {
+			err = ErrReturned
+		}
+		a.logFailure(err)
+
+		if a.backoff == nil {
+			a.reportFailure(err, "")
+			if a.shutdowner != nil {
+				if err := a.shutdowner.Shutdown(); err != nil && a.log != nil {
+					a.log.Error("could not shut down after actor failure:", err)
+				}
+			}
+			return
+		}
+
+		if time.Since(begin) >= a.backoff.Max {
+			delay = a.backoff.Initial
+		}
+		a.reportFailure(err, fmt.Sprintf("; restarting in %s", delay))
+
+		select {
+		case <-time.After(delay):
+		case <-draining:
+			return
+		}
+		delay = a.backoff.next(delay)
+
+		if a.healthHandle != nil {
+			a.healthHandle.SetStarting()
+		}
+		a.recordRestart()
+	}
+}
+
+// runOnce runs the run function once, with liveness monitoring, converting a
+// panic into a *PanicError.
+func (a *Actor) runOnce(ctx context.Context, runFunc RunFunc) (err error) {
 	alive, stopLiveness := a.livenessMonitor()
 	defer stopLiveness()
-	runFunc(ctx, alive)
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: string(debug.Stack())}
+		}
+	}()
+	return runFunc(ctx, alive)
+}
+
+// logFailure logs a failure of the run function, if configured to do so.
+func (a *Actor) logFailure(err error) {
+	if a.log == nil {
+		return
+	}
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		a.log.Error("actor failed:", err, "\n", panicErr.Stack)
+	} else {
+		a.log.Error("actor failed:", err)
+	}
+}
+
+// reportFailure reports a failure of the run function to the health handle,
+// with the given suffix appended to the message.
+func (a *Actor) reportFailure(err error, suffix string) {
+	if a.healthHandle == nil {
+		return
+	}
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		a.healthHandle.RecordStack(panicErr.Stack)
+	}
+	a.healthHandle.SetUnhealthy(err.Error() + suffix)
 }
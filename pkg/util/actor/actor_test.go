@@ -7,14 +7,19 @@ package actor
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/config"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/flare"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/health"
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/log"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/telemetry"
 	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/comptest"
 	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/startup"
 	"github.com/stretchr/testify/require"
@@ -25,7 +30,7 @@ func TestWithoutComponents(t *testing.T) {
 	actor := Actor{}
 	ch := make(chan int)
 
-	run := func(ctx context.Context, alive <-chan struct{}) {
+	run := func(ctx context.Context, alive <-chan struct{}) error {
 		for {
 			select {
 			case <-alive:
@@ -33,7 +38,7 @@ func TestWithoutComponents(t *testing.T) {
 				fmt.Printf("GOT: %d\n", v)
 			case <-ctx.Done():
 				fmt.Println("Stopping")
-				return
+				return nil
 			}
 		}
 	}
@@ -61,7 +66,7 @@ func newTestComp(lc fx.Lifecycle) (*testComp, health.Registration) {
 	return c, reg
 }
 
-func (c *testComp) run(ctx context.Context, alive <-chan struct{}) {
+func (c *testComp) run(ctx context.Context, alive <-chan struct{}) error {
 	// this is healthy for about 5ms, then unhealthy for about 5ms, and repeats
 	// that pattern.
 	tkr := time.NewTicker(10 * time.Millisecond)
@@ -71,7 +76,7 @@ func (c *testComp) run(ctx context.Context, alive <-chan struct{}) {
 		case <-tkr.C:
 			time.Sleep(5 * time.Millisecond) // unhealthy for a few ms
 		case <-ctx.Done():
-			return
+			return nil
 		}
 	}
 }
@@ -90,12 +95,505 @@ func TestWithHealth(t *testing.T) {
 	).WithRunningApp(func() {
 		// see it go unhealthy..
 		require.Eventually(t, func() bool {
-			return !h.GetHealth()["test-comp"].Healthy
+			return h.GetHealth()["test-comp"].Status == health.Unhealthy
 		}, time.Second, time.Millisecond)
 
 		// see it return to healthy..
 		require.Eventually(t, func() bool {
-			return !h.GetHealth()["test-comp"].Healthy
+			return h.GetHealth()["test-comp"].Status == health.Healthy
 		}, time.Second, time.Millisecond)
 	})
 }
+
+type stuckComp struct {
+	actor   Actor
+	unblock chan struct{}
+}
+
+func newStuckComp(lc fx.Lifecycle) (*stuckComp, health.Registration) {
+	reg := health.NewRegistration("stuck-comp")
+	c := &stuckComp{unblock: make(chan struct{})}
+	c.actor.MonitorLiveness(reg.Handle, time.Millisecond)
+	c.actor.HookLifecycle(lc, c.run)
+	return c, reg
+}
+
+func (c *stuckComp) run(ctx context.Context, alive <-chan struct{}) error {
+	// get stuck until unblocked
+	<-c.unblock
+	for {
+		select {
+		case <-alive:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func TestLivenessStack(t *testing.T) {
+	var comp *stuckComp
+	var h health.Component
+	var f flare.Component
+	comptest.FxTest(t,
+		fx.Supply(core.BundleParams{AutoStart: startup.Never}),
+		health.Module,
+		log.Module,
+		config.MockModule,
+		flare.MockModule,
+		fx.Provide(newStuckComp),
+		fx.Populate(&comp),
+		fx.Populate(&h),
+		fx.Populate(&f),
+	).WithRunningApp(func() {
+		require.Eventually(t, func() bool {
+			return h.GetHealth()["stuck-comp"].Status == health.Unhealthy
+		}, time.Second, time.Millisecond)
+
+		msg := h.GetHealth()["stuck-comp"].Message
+		require.Contains(t, msg, "health check timed out")
+		require.Contains(t, msg, "chan receive in actor.(*stuckComp).run")
+
+		stack, err := f.(flare.Mock).GetFlareFile(t, "liveness-stacks/stuck-comp.txt")
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(stack, "goroutine "))
+		require.Contains(t, stack, "(*stuckComp).run")
+
+		// the stack is cleared once the actor recovers
+		close(comp.unblock)
+		require.Eventually(t, func() bool {
+			return h.GetHealth()["stuck-comp"].Status == health.Healthy
+		}, time.Second, time.Millisecond)
+		_, err = f.(flare.Mock).GetFlareFile(t, "liveness-stacks/stuck-comp.txt")
+		require.Error(t, err)
+	})
+}
+
+func TestLivenessPanic(t *testing.T) {
+	panicked := make(chan interface{}, 1)
+	oldPanicFunc := panicFunc
+	panicFunc = func(v interface{}) { panicked <- v }
+	defer func() { panicFunc = oldPanicFunc }()
+
+	var comp *stuckComp
+	comptest.FxTest(t,
+		fx.Supply(core.BundleParams{AutoStart: startup.Never}),
+		health.Module,
+		log.Module,
+		config.MockModule,
+		fx.Invoke(func(c config.Component) { c.(config.Mock).Set("health_liveness_panic_after", 3) }),
+		fx.Provide(newStuckComp),
+		fx.Populate(&comp),
+		fx.Invoke(func(health.Component) {}),
+	).WithRunningApp(func() {
+		defer close(comp.unblock)
+
+		select {
+		case v := <-panicked:
+			require.Contains(t, v, "missed 3 consecutive liveness checks")
+			require.Contains(t, v, "(*stuckComp).run")
+		case <-time.After(time.Second):
+			require.Fail(t, "liveness monitor did not panic")
+		}
+	})
+}
+
+func TestRestart(t *testing.T) {
+	actor := New()
+	ch := make(chan int)
+	got := make(chan int)
+
+	run := func(ctx context.Context, alive <-chan struct{}) error {
+		for {
+			select {
+			case <-alive:
+			case v := <-ch:
+				got <- v
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+
+	actor.Start(run)
+	ch <- 1
+	require.Equal(t, 1, <-got)
+
+	require.NoError(t, actor.Restart(context.Background()))
+	ch <- 2
+	require.Equal(t, 2, <-got)
+	require.Equal(t, 1, actor.Restarts())
+
+	// a stopped actor can be started again
+	require.NoError(t, actor.Stop(context.Background()))
+	actor.Start(run)
+	ch <- 3
+	require.Equal(t, 3, <-got)
+	require.NoError(t, actor.Stop(context.Background()))
+}
+
+type flakyComp struct {
+	actor Actor
+	runs  chan int
+}
+
+func newFlakyComp(lc fx.Lifecycle) (*flakyComp, health.Registration) {
+	reg := health.NewRegistration("flaky-comp")
+	c := &flakyComp{runs: make(chan int, 10)}
+	c.actor.MonitorLiveness(reg.Handle, time.Millisecond)
+	c.actor.RestartOnFailure(Backoff{Initial: time.Millisecond, Max: 10 * time.Millisecond})
+	c.actor.HookLifecycle(lc, c.run)
+	return c, reg
+}
+
+func (c *flakyComp) run(ctx context.Context, alive <-chan struct{}) error {
+	// fail on the first run, panic on the second, and then run normally
+	run := len(c.runs)
+	c.runs <- run
+	switch run {
+	case 0:
+		return errors.New("oops")
+	case 1:
+		panic("uhoh")
+	}
+	for {
+		select {
+		case <-alive:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func TestRestartAfterStopTimeout(t *testing.T) {
+	actor := New()
+	release := make(chan struct{})
+	var running, peak int32
+	actor.Start(func(ctx context.Context, alive <-chan struct{}) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		if n > atomic.LoadInt32(&peak) {
+			atomic.StoreInt32(&peak, n)
+		}
+		// ignore cancellation until released
+		<-release
+		<-ctx.Done()
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, actor.Stop(ctx), context.DeadlineExceeded)
+
+	// the goroutine is still running, so it is not restarted alongside itself
+	require.ErrorIs(t, actor.Restart(ctx), context.DeadlineExceeded)
+	require.Panics(t, func() { actor.Start(func(context.Context, <-chan struct{}) error { return nil }) })
+
+	// once it stops, the actor can be restarted
+	close(release)
+	require.NoError(t, actor.Restart(context.Background()))
+	require.Equal(t, 1, actor.Restarts())
+	require.NoError(t, actor.Stop(context.Background()))
+	require.Equal(t, int32(1), atomic.LoadInt32(&peak))
+}
+
+func TestRestartOnFailure(t *testing.T) {
+	var comp *flakyComp
+	var h health.Component
+	comptest.FxTest(t,
+		fx.Supply(core.BundleParams{AutoStart: startup.Never}),
+		health.Module,
+		log.Module,
+		config.MockModule,
+		fx.Provide(newFlakyComp),
+		fx.Populate(&comp),
+		fx.Populate(&h),
+	).WithRunningApp(func() {
+		require.Eventually(t, func() bool {
+			ch := h.GetHealth()["flaky-comp"]
+			return ch.Restarts == 2 && ch.State == health.Ready && ch.Status == health.Healthy
+		}, time.Second, time.Millisecond)
+		require.Equal(t, 2, comp.actor.Restarts())
+		require.Equal(t, 3, len(comp.runs))
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	b := Backoff{Initial: time.Second, Max: 5 * time.Second}
+	delay := b.Initial
+	var delays []time.Duration
+	for i := 0; i < 5; i++ {
+		delays = append(delays, delay)
+		delay = b.next(delay)
+	}
+	require.Equal(t, []time.Duration{
+		time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second,
+	}, delays)
+}
+
+type fakeShutdowner struct {
+	shutdown chan struct{}
+}
+
+func (s *fakeShutdowner) Shutdown(...fx.ShutdownOption) error {
+	close(s.shutdown)
+	return nil
+}
+
+func TestFailures(t *testing.T) {
+	cases := []struct {
+		name    string
+		run     RunFunc
+		message string
+		stack   bool
+	}{
+		{
+			name: "error",
+			run: func(context.Context, <-chan struct{}) error {
+				return errors.New("oops")
+			},
+			message: "oops",
+		},
+		{
+			name: "return",
+			run: func(context.Context, <-chan struct{}) error {
+				return nil
+			},
+			message: ErrReturned.Error(),
+		},
+		{
+			name: "panic",
+			run: func(context.Context, <-chan struct{}) error {
+				panic("uhoh")
+			},
+			message: "run function panicked: uhoh",
+			stack:   true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			reg := health.NewRegistration("failing-comp")
+			shutdowner := &fakeShutdowner{shutdown: make(chan struct{})}
+			var h health.Component
+			var l log.Component
+			var f flare.Component
+			comptest.FxTest(t,
+				fx.Supply(core.BundleParams{AutoStart: startup.Never}),
+				health.Module,
+				log.MockModule,
+				config.MockModule,
+				flare.MockModule,
+				fx.Supply(reg),
+				fx.Invoke(func(lc fx.Lifecycle, l log.Component) {
+					l.(log.Mock).StartCapture()
+					actor := New()
+					actor.MonitorLiveness(reg.Handle, time.Millisecond)
+					actor.LogFailures(l)
+					actor.ShutdownOnFailure(shutdowner)
+					actor.HookLifecycle(lc, tc.run)
+				}),
+				fx.Populate(&h),
+				fx.Populate(&l),
+				fx.Populate(&f),
+			).WithRunningApp(func() {
+				select {
+				case <-shutdowner.shutdown:
+				case <-time.After(time.Second):
+					require.Fail(t, "actor did not shut down the app")
+				}
+
+				ch := h.GetHealth()["failing-comp"]
+				require.Equal(t, health.Unhealthy, ch.Status)
+				require.Equal(t, tc.message, ch.Message)
+
+				captured := strings.Join(l.(log.Mock).Captured(), "")
+				require.Contains(t, captured, "actor failed: "+tc.message)
+
+				_, err := f.(flare.Mock).GetFlareFile(t, "liveness-stacks/failing-comp.txt")
+				if tc.stack {
+					require.NoError(t, err)
+					require.Contains(t, captured, "TestFailures")
+				} else {
+					require.Error(t, err)
+				}
+			})
+		})
+	}
+}
+
+func TestStopIsNotFailure(t *testing.T) {
+	actor := New()
+	shutdowner := &fakeShutdowner{shutdown: make(chan struct{})}
+	actor.ShutdownOnFailure(shutdowner)
+
+	actor.Start(func(ctx context.Context, alive <-chan struct{}) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	require.NoError(t, actor.Stop(context.Background()))
+
+	select {
+	case <-shutdowner.shutdown:
+		require.Fail(t, "actor shut down the app when stopped")
+	default:
+	}
+}
+
+func TestDrainOnStop(t *testing.T) {
+	actor := New()
+	actor.DrainOnStop()
+	shutdowner := &fakeShutdowner{shutdown: make(chan struct{})}
+	actor.ShutdownOnFailure(shutdowner)
+
+	queue := make(chan int, 10)
+	var drained []int
+	var wasDraining bool
+	actor.Start(func(ctx context.Context, alive <-chan struct{}) error {
+		draining := Draining(ctx)
+		for {
+			select {
+			case <-alive:
+			case <-draining:
+				wasDraining = IsDraining(ctx)
+				for {
+					select {
+					case v := <-queue:
+						drained = append(drained, v)
+					default:
+						return nil
+					}
+				}
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	})
+
+	for i := 0; i < 3; i++ {
+		queue <- i
+	}
+	require.NoError(t, actor.Stop(context.Background()))
+	require.True(t, wasDraining)
+	require.Equal(t, []int{0, 1, 2}, drained)
+
+	// returning while draining is not a failure
+	select {
+	case <-shutdowner.shutdown:
+		require.Fail(t, "actor shut down the app when drained")
+	default:
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	actor := New()
+	actor.DrainOnStop()
+
+	cancelled := make(chan bool, 1)
+	actor.Start(func(ctx context.Context, alive <-chan struct{}) error {
+		// draining never finishes, so the context is cancelled
+		<-Draining(ctx)
+		<-ctx.Done()
+		cancelled <- IsDraining(ctx)
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, actor.Stop(ctx), context.DeadlineExceeded)
+	require.False(t, <-cancelled)
+}
+
+func TestStopWithoutDrain(t *testing.T) {
+	actor := New()
+	stopped := make(chan bool, 1)
+	actor.Start(func(ctx context.Context, alive <-chan struct{}) error {
+		<-ctx.Done()
+		// draining and cancellation occur together
+		stopped <- isClosed(Draining(ctx))
+		return nil
+	})
+	require.NoError(t, actor.Stop(context.Background()))
+	require.True(t, <-stopped)
+
+	// contexts from elsewhere are never draining
+	require.Nil(t, Draining(context.Background()))
+	require.False(t, IsDraining(context.Background()))
+}
+
+type slowComp struct {
+	actor Actor
+}
+
+func newSlowComp(lc fx.Lifecycle) (*slowComp, health.Registration) {
+	reg := health.NewRegistration("slow-comp")
+	c := &slowComp{}
+	c.actor.MonitorLiveness(reg.Handle, 50*time.Millisecond)
+	c.actor.DegradeOnLatency(time.Millisecond)
+	c.actor.HookLifecycle(lc, c.run)
+	return c, reg
+}
+
+func (c *slowComp) run(ctx context.Context, alive <-chan struct{}) error {
+	// each iteration is slower than the threshold, but faster than the period
+	tkr := time.NewTicker(time.Millisecond)
+	for {
+		select {
+		case <-alive:
+		case <-tkr.C:
+			time.Sleep(10 * time.Millisecond)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func TestDegradeOnLatency(t *testing.T) {
+	var comp *slowComp
+	var h health.Component
+	comptest.FxTest(t,
+		fx.Supply(core.BundleParams{AutoStart: startup.Never}),
+		health.Module,
+		log.Module,
+		config.MockModule,
+		fx.Provide(newSlowComp),
+		fx.Populate(&comp),
+		fx.Populate(&h),
+	).WithRunningApp(func() {
+		require.Eventually(t, func() bool {
+			return h.GetHealth()["slow-comp"].Status == health.Degraded
+		}, time.Second, time.Millisecond)
+		require.Regexp(t, `^loop latency \d+ms exceeds 1ms$`, h.GetHealth()["slow-comp"].Message)
+	})
+}
+
+func TestLivenessTelemetry(t *testing.T) {
+	var comp *stuckComp
+	var h health.Component
+	var tel telemetry.Component
+	comptest.FxTest(t,
+		fx.Supply(core.BundleParams{AutoStart: startup.Never}),
+		health.Module,
+		log.Module,
+		config.MockModule,
+		telemetry.Module,
+		fx.Provide(newStuckComp),
+		fx.Populate(&comp),
+		fx.Populate(&h),
+		fx.Populate(&tel),
+	).WithRunningApp(func() {
+		require.Eventually(t, func() bool {
+			return strings.Contains(h.GetHealth()["stuck-comp"].Message, "ticks missed")
+		}, time.Second, time.Millisecond)
+
+		// once unblocked, the latency includes the time spent stuck
+		close(comp.unblock)
+		require.Eventually(t, func() bool {
+			return h.GetHealth()["stuck-comp"].Status == health.Healthy
+		}, time.Second, time.Millisecond)
+
+		var bldr strings.Builder
+		require.NoError(t, tel.WriteText(&bldr))
+		require.Contains(t, bldr.String(), `liveness_ticks_missed{component="stuck-comp"}`)
+		require.Contains(t, bldr.String(), `liveness_latency_seconds_count{component="stuck-comp"}`)
+	})
+}
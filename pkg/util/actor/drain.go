@@ -0,0 +1,59 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package actor
+
+import (
+	"context"
+)
+
+// DrainOnStop indicates that the actor should drain its queued work when it is
+// stopped, rather than stopping immediately.
+//
+// When Stop is called, the run function's Draining channel is closed, but its
+// context is not yet cancelled.  The run function should stop accepting new
+// work (such as by closing its input pipe), finish the work already queued,
+// and return nil.  If it has not returned by the time the context given to
+// Stop is cancelled, its own context is cancelled, and it should return
+// promptly, abandoning any remaining work.
+func (a *Actor) DrainOnStop() {
+	a.drainOnStop = true
+}
+
+// drainingKey is the context key for the draining channel.
+type drainingKey struct{}
+
+// Draining returns a channel that is closed when the actor running the run
+// function that received the given context begins to stop.  For actors that
+// drain on stop, this signals that the run function should finish its queued
+// work, while ctx.Done() signals that it should stop immediately.  Otherwise,
+// both occur together.
+//
+// This returns nil, which never closes, for contexts that did not come from an
+// actor.
+func Draining(ctx context.Context) <-chan struct{} {
+	draining, _ := ctx.Value(drainingKey{}).(chan struct{})
+	return draining
+}
+
+// IsDraining returns true if the actor running the run function that received
+// the given context is draining: it has begun to stop, but its context has not
+// yet been cancelled.
+func IsDraining(ctx context.Context) bool {
+	return ctx.Err() == nil && isClosed(Draining(ctx))
+}
+
+// isClosed returns true if the given channel is closed.
+func isClosed(ch <-chan struct{}) bool {
+	if ch == nil {
+		return false
+	}
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
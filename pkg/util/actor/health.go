@@ -7,6 +7,8 @@ package actor
 
 import (
 	"context"
+	"fmt"
+	"runtime/debug"
 	"time"
 
 	"github.com/DataDog/dd-agent-comp-experiments/comp/core/health"
@@ -15,45 +17,121 @@ import (
 // MonitorLiveness indicates that the actor should report its "liveness" to the
 // given Health handle.
 //
+// The handle is marked Starting when the actor starts, Ready once the actor
+// first reads from its `alive` channel, and Stopped when the actor stops.
+//
+// Every period, the monitor offers a tick on the `alive` channel, and measures
+// the time the actor's loop takes to read it.  This latency is recorded as
+// telemetry.  A tick that is not read within the period is missed, and marks
+// the actor unhealthy until it is read.  With DegradeOnLatency, a tick that
+// is read late, but within the period, marks the actor degraded.
+//
+// When a liveness check fails, the actor goroutine's stack is captured.  The
+// health message summarizes where the goroutine is stuck, and the full stack is
+// recorded with the handle for inclusion in flares, until the actor next
+// passes a liveness check.  If the handle's LivenessPanicAfter is non-zero, the
+// monitor panics after that many consecutive failed checks, crashing the agent
+// with a full goroutine dump.  The panic occurs on the monitor's goroutine, so
+// the panic value includes the stack of the stuck actor goroutine.
+//
 // The given period should be comfortably longer than the longest time between
-// runs of the component's main loop.
+// runs of the component's main loop.  If `health_liveness_period` is
+// configured, it is used instead.
 func (a *Actor) MonitorLiveness(handle *health.Handle, period time.Duration) {
 	a.healthHandle = handle
 	a.livenessPeriod = period
 }
 
+// DegradeOnLatency indicates that the actor should be reported as degraded
+// when its loop takes longer than the given threshold, but less than the
+// liveness period, to respond to a liveness check.  This gives warning of a
+// slow loop before it becomes unhealthy.
+func (a *Actor) DegradeOnLatency(threshold time.Duration) {
+	a.degradedLatency = threshold
+}
+
+// panicFunc is called to panic when too many liveness checks have failed.  It
+// is a variable to support testing.
+var panicFunc = func(v interface{}) {
+	// the default traceback only includes the panicking goroutine
+	debug.SetTraceback("all")
+	panic(v)
+}
+
 // This method must not be called before the monitored component has started.
+// It must be called from the actor goroutine.
 func (a *Actor) livenessMonitor() (<-chan struct{}, func()) {
 	// if MonitorLiveness wasn't called, do nothing
 	if a.healthHandle == nil {
 		return make(chan struct{}), func() {}
 	}
 
-	ch := make(chan struct{}, 1) // capacity=1 to allow one tick to elapse before failure
+	ch := make(chan struct{}) // unbuffered, so that a send completes when the actor reads
 	stopped := make(chan struct{})
 	ctx, cancel := context.WithCancel(context.Background())
+	goroutineID := currentGoroutineID()
+	panicAfter := a.healthHandle.LivenessPanicAfter()
+	period := a.healthHandle.LivenessPeriod(a.livenessPeriod)
 
 	go func() {
 		defer close(stopped)
-		tkr := time.NewTicker(a.livenessPeriod)
+		tkr := time.NewTicker(period)
+		defer tkr.Stop()
+		ready := false
+		misses := 0
+		var stack, summary string
 		for {
+			// wait for the next tick
 			select {
 			case <-ctx.Done():
 				return
 			case <-tkr.C:
+			}
+
+			// offer the tick until the actor reads it, counting each period
+			// that passes as a missed tick
+			offered := time.Now()
+		offer:
+			for {
 				select {
 				case <-ctx.Done():
 					return
 				case ch <- struct{}{}:
-					// we were able to add an item to the channel, so the
-					// component is healthy
-					a.healthHandle.SetHealthy()
-				default:
-					// we are not stopped, and were not able to write an item,
-					// so the component is unhealthy
-					a.healthHandle.SetUnhealthy("health check timed out")
+					break offer
+				case <-tkr.C:
+					misses++
+					a.healthHandle.RecordMissedTick()
+					if misses == 1 {
+						stack = goroutineStack(goroutineID)
+						a.healthHandle.RecordStack(stack)
+						summary = summarizeStack(stack)
+					}
+					a.healthHandle.SetUnhealthy(livenessMessage(misses, summary))
+					if panicAfter > 0 && misses >= panicAfter {
+						panicFunc(fmt.Sprintf(
+							"actor goroutine %d missed %d consecutive liveness checks:\n\n%s",
+							goroutineID, misses, stack))
+						return
+					}
 				}
 			}
+
+			// the actor has read from the channel, so it is ready, and healthy
+			// unless it was slow to do so
+			latency := time.Since(offered)
+			a.healthHandle.RecordLiveness(latency)
+			if a.degradedLatency > 0 && latency > a.degradedLatency {
+				a.healthHandle.SetDegraded(fmt.Sprintf(
+					"loop latency %s exceeds %s", latency.Round(time.Millisecond), a.degradedLatency))
+			} else {
+				a.healthHandle.SetHealthy()
+				a.healthHandle.ClearStack()
+			}
+			if !ready {
+				a.healthHandle.SetReady()
+				ready = true
+			}
+			misses = 0
 		}
 	}()
 
@@ -64,3 +142,15 @@ func (a *Actor) livenessMonitor() (<-chan struct{}, func()) {
 
 	return ch, stop
 }
+
+// livenessMessage builds the health message for a failed liveness check.
+func livenessMessage(misses int, summary string) string {
+	message := "health check timed out"
+	if misses > 1 {
+		message = fmt.Sprintf("%s (%d ticks missed)", message, misses)
+	}
+	if summary != "" {
+		message = fmt.Sprintf("%s: %s", message, summary)
+	}
+	return message
+}
@@ -0,0 +1,95 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package actor
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// currentGoroutineID returns the ID of the calling goroutine, parsed from the
+// header of its stack trace, or 0 if this is not possible.
+func currentGoroutineID() int64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+
+	// the first line looks like `goroutine 123 [running]:`
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	if i := bytes.IndexByte(buf, ' '); i >= 0 {
+		buf = buf[:i]
+	}
+	id, err := strconv.ParseInt(string(buf), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// allGoroutineStacks returns a dump of the stacks of all goroutines.
+func allGoroutineStacks() string {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// goroutineStack returns the stack of the goroutine with the given ID.  If
+// that goroutine cannot be found, it returns a dump of all goroutines.
+func goroutineStack(id int64) string {
+	all := allGoroutineStacks()
+	header := fmt.Sprintf("goroutine %d [", id)
+
+	// goroutines are separated by blank lines
+	for _, stack := range strings.Split(all, "\n\n") {
+		if strings.HasPrefix(stack, header) {
+			return strings.TrimRight(stack, "\n") + "\n"
+		}
+	}
+	return all
+}
+
+// summarizeStack summarizes the goroutine's state and the function in which it is
+// currently executing, based on a stack returned from goroutineStack, such as
+// `chan send in processor.(*processor).run (processor.go:73)`.  It returns an empty
+// string if the stack cannot be parsed.
+func summarizeStack(stack string) string {
+	lines := strings.Split(stack, "\n")
+	if len(lines) < 3 {
+		return ""
+	}
+
+	// header is `goroutine 123 [chan send, 2 minutes]:`
+	header := lines[0]
+	start, end := strings.IndexByte(header, '['), strings.LastIndexByte(header, ']')
+	if start < 0 || end < start {
+		return ""
+	}
+	state := header[start+1 : end]
+
+	// function is `pkg/path.(*type).method(0x1234, ...)`
+	function := lines[1]
+	if i := strings.LastIndexByte(function, '('); i > 0 {
+		function = function[:i]
+	}
+	function = filepath.Base(function)
+
+	// location is `\t/path/to/file.go:123 +0x1a`
+	location := strings.TrimSpace(lines[2])
+	if i := strings.IndexByte(location, ' '); i >= 0 {
+		location = location[:i]
+	}
+	location = filepath.Base(location)
+
+	return fmt.Sprintf("%s in %s (%s)", state, function, location)
+}
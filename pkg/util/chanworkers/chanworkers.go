@@ -0,0 +1,636 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package chanworkers provides a pool of workers that process items from a
+// shared input channel concurrently.  It is the concurrent counterpart of
+// pkg/util/actor, for components whose processing of each item is
+// independent and too costly for a single goroutine.
+//
+// Each worker calls a Func for each item it takes from the input, and the
+// pool sends the results to an output pipe.  By default, results are sent in
+// the order they are produced; with Ordered, they are sent in the order their
+// items were read from the input.
+//
+// The number of workers can be changed while the pool is running, with
+// Resize.  When the pool stops, it first drains its input, processing the
+// items already waiting there, until the Stop context is done.
+//
+// As with actors, the pool can monitor the liveness of its workers, reporting
+// to a health handle, and recovers and logs panics from the Func.  A panic
+// drops the item being processed, and marks the pool as degraded.  Liveness
+// checks measure each worker's latency and count missed ticks as actors do,
+// but do not capture the stacks of stuck workers, nor panic after
+// `health_liveness_panic_after` missed checks.  Methods on
+// a pool other than Resize and Size are not re-entrant.  Components using a
+// pool should _either_ call HookLifecycle once in their constructor or call
+// Start and Stop from their lifecycle hook.
+package chanworkers
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/health"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/log"
+	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/pipe"
+	"go.uber.org/fx"
+)
+
+// Func processes a single item, returning its result.  The context is
+// cancelled if the pool is stopped before it has finished draining.
+type Func[In, Out any] func(ctx context.Context, item In) Out
+
+// maxInFlight is the largest number of items an Ordered pool processes or
+// holds for output at once.
+const maxInFlight = 1024
+
+// Option configures a pool.
+type Option func(*options)
+
+type options struct {
+	workers int
+	ordered bool
+}
+
+// WithWorkers sets the initial number of workers, which defaults to the number
+// of CPUs.
+func WithWorkers(workers int) Option {
+	return func(o *options) { o.workers = workers }
+}
+
+// Ordered causes results to be sent to the output in the order their items
+// were read from the input, rather than the order in which they are produced.
+func Ordered() Option {
+	return func(o *options) { o.ordered = true }
+}
+
+// Pool runs workers over a shared input channel.
+type Pool[In, Out any] struct {
+	// dropped counts items dropped because fn panicked, and is accessed
+	// atomically.  It is first in the struct to ensure 64-bit alignment.
+	dropped uint64
+
+	// in is the input channel
+	in <-chan In
+
+	// out is the output pipe, or nil if results are discarded
+	out pipe.Sender[Out]
+
+	// fn processes each item
+	fn Func[In, Out]
+
+	// ordered is true if results are sent in input order
+	ordered bool
+
+	// healthHandle is the handle to which liveness data should be reported.
+	// If this is nil, liveness is not monitored.
+	healthHandle *health.Handle
+
+	// livenessPeriod is the period passed to MonitorLiveness.
+	livenessPeriod time.Duration
+
+	// log is the logger passed to LogFailures, or nil if panics should not
+	// be logged.
+	log log.Component
+
+	// ctx is passed to fn, and cancel cancels it when stopping hard.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// draining is closed when the pool begins to stop.
+	draining chan struct{}
+
+	// tasks carries items from the dispatcher to the workers.
+	tasks chan task[In, Out]
+
+	// pending carries the result slots of an Ordered pool, in input order,
+	// from the dispatcher to the emitter.
+	pending chan chan result[Out]
+
+	// stopped is closed once the dispatcher, workers, and emitter have
+	// finished.
+	stopped chan struct{}
+
+	// stopMonitor is closed to stop the liveness monitor, and monitorStopped
+	// is closed once it has stopped.
+	stopMonitor    chan struct{}
+	monitorStopped chan struct{}
+
+	// wg counts the running goroutines, other than the liveness monitor.
+	wg sync.WaitGroup
+
+	// mu covers the remaining fields.
+	mu sync.Mutex
+
+	// started is true after the pool has been started, and remains true after
+	// it has stopped.
+	started bool
+
+	// size is the requested number of workers.
+	size int
+
+	// workers are the running workers.
+	workers []*worker
+
+	// nextID is the ID of the next worker.
+	nextID int
+
+	// finished is true once no new workers are needed, because the tasks
+	// channel has been closed.
+	finished bool
+
+	// lastPanic describes the most recent panic from fn.
+	lastPanic string
+}
+
+// task is an item to be processed by a worker.
+type task[In, Out any] struct {
+	item In
+
+	// slot receives the result, for Ordered pools
+	slot chan result[Out]
+}
+
+// result is the result of a task.  The result is not valid if fn panicked.
+type result[Out any] struct {
+	value Out
+	valid bool
+}
+
+// worker is the state of a single worker.
+type worker struct {
+	// id identifies the worker in health messages.
+	id int
+
+	// alive is used for liveness monitoring, as with actors.
+	alive chan struct{}
+
+	// quit is closed to stop this worker when the pool shrinks.
+	quit chan struct{}
+
+	// exited is closed when the worker's goroutine returns.
+	exited chan struct{}
+}
+
+// New creates a new pool processing items from in with fn, and sending the
+// results to out.  If out is nil, results are discarded.
+func New[In, Out any](in <-chan In, out pipe.Sender[Out], fn Func[In, Out], opts ...Option) *Pool[In, Out] {
+	o := options{workers: runtime.NumCPU()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.workers < 1 {
+		o.workers = 1
+	}
+	return &Pool[In, Out]{
+		in:      in,
+		out:     out,
+		fn:      fn,
+		ordered: o.ordered,
+		size:    o.workers,
+	}
+}
+
+// MonitorLiveness indicates that the pool should report the liveness of its
+// workers to the given health handle, as with actor.MonitorLiveness.  Every
+// period, each running worker is offered a tick, and the time it takes to read
+// it is recorded as telemetry.  The pool is unhealthy while any worker has not
+// read its tick within the period.  Workers that have exited, such as after
+// the input is closed, are not checked.
+//
+// The given period should be comfortably longer than the longest time taken
+// to process an item.  If `health_liveness_period` is configured, it is used
+// instead.
+func (p *Pool[In, Out]) MonitorLiveness(handle *health.Handle, period time.Duration) {
+	p.healthHandle = handle
+	p.livenessPeriod = period
+}
+
+// LogFailures indicates that the pool should log panics from its Func,
+// including their stack traces, to the given logger.  Regardless, the item
+// being processed is dropped and counted (see Dropped), the pool is reported
+// as degraded to the handle given to MonitorLiveness, if any, and the worker
+// continues with the next item.
+func (p *Pool[In, Out]) LogFailures(log log.Component) {
+	p.log = log
+}
+
+// HookLifecycle connects this pool to the given fx.Lifecycle, starting and
+// stopping it with the lifecycle.  Use this method _or_ the Start and Stop
+// methods, but not both.
+func (p *Pool[In, Out]) HookLifecycle(lc fx.Lifecycle) {
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			p.Start()
+			return nil
+		},
+		OnStop: p.Stop,
+	})
+}
+
+// Start starts the pool's workers.
+func (p *Pool[In, Out]) Start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.started {
+		panic("Pool has already been started")
+	}
+	p.started = true
+
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	p.draining = make(chan struct{})
+	p.tasks = make(chan task[In, Out])
+	p.stopped = make(chan struct{})
+	if p.ordered {
+		p.pending = make(chan chan result[Out], maxInFlight)
+	}
+
+	if p.healthHandle != nil {
+		p.healthHandle.SetStarting()
+	}
+
+	for len(p.workers) < p.size {
+		p.startWorker()
+	}
+
+	p.wg.Add(1)
+	go p.dispatch()
+	if p.ordered {
+		p.wg.Add(1)
+		go p.emit()
+	}
+	go func() {
+		p.wg.Wait()
+		close(p.stopped)
+	}()
+
+	if p.healthHandle != nil {
+		p.stopMonitor = make(chan struct{})
+		p.monitorStopped = make(chan struct{})
+		go p.monitorLiveness()
+	}
+}
+
+// Stop stops the pool.  The pool stops reading from its input once the input
+// is empty, finishes processing the items it has read, and sends their
+// results.  If the given context is done before that is complete, the context
+// passed to the Func is cancelled and Stop returns the context's error without
+// waiting further.
+func (p *Pool[In, Out]) Stop(ctx context.Context) error {
+	p.mu.Lock()
+	started := p.started
+	p.mu.Unlock()
+	if !started {
+		panic("Pool has not been started")
+	}
+	if p.cancel == nil {
+		panic("Pool has already been stopped")
+	}
+	close(p.draining)
+	cancel := p.cancel
+	p.cancel = nil
+
+	var err error
+	select {
+	case <-p.stopped:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	cancel()
+
+	// stop the liveness monitor before reporting the pool as stopped, so
+	// that it cannot report health afterward
+	if p.healthHandle != nil {
+		close(p.stopMonitor)
+		<-p.monitorStopped
+		p.healthHandle.SetStopped()
+	}
+	return err
+}
+
+// Dropped returns the number of items that have been dropped because the Func
+// panicked while processing them.
+func (p *Pool[In, Out]) Dropped() uint64 {
+	return atomic.LoadUint64(&p.dropped)
+}
+
+// Resize changes the number of workers, to a minimum of one.  Added workers
+// start immediately, and removed workers stop after processing their current
+// item.  This can be called concurrently with other methods, and before the
+// pool is started.
+func (p *Pool[In, Out]) Resize(workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.size = workers
+
+	// workers are started by Start, and are not needed once finished
+	if !p.started || p.finished {
+		return
+	}
+	for len(p.workers) < p.size {
+		p.startWorker()
+	}
+	for len(p.workers) > p.size {
+		last := len(p.workers) - 1
+		close(p.workers[last].quit)
+		p.workers = p.workers[:last]
+	}
+}
+
+// Size returns the number of workers.
+func (p *Pool[In, Out]) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.size
+}
+
+// startWorker starts a new worker.  It must be called with the mutex held.
+func (p *Pool[In, Out]) startWorker() {
+	w := &worker{
+		id:     p.nextID,
+		alive:  make(chan struct{}), // unbuffered, so that a send completes when the worker reads
+		quit:   make(chan struct{}),
+		exited: make(chan struct{}),
+	}
+	p.nextID++
+	p.workers = append(p.workers, w)
+	p.wg.Add(1)
+	go p.work(w)
+}
+
+// dispatch reads items from the input and hands them to the workers, until the
+// input is closed or empty while draining.  It runs in a dedicated goroutine.
+func (p *Pool[In, Out]) dispatch() {
+	defer p.wg.Done()
+	defer func() {
+		p.mu.Lock()
+		p.finished = true
+		p.mu.Unlock()
+		close(p.tasks)
+		if p.ordered {
+			close(p.pending)
+		}
+	}()
+
+	for {
+		var item In
+		var ok bool
+		select {
+		case item, ok = <-p.in:
+		case <-p.draining:
+			// take only the items that are already waiting
+			select {
+			case item, ok = <-p.in:
+			default:
+				return
+			}
+		case <-p.ctx.Done():
+			return
+		}
+		if !ok {
+			return
+		}
+
+		t := task[In, Out]{item: item}
+		if p.ordered {
+			t.slot = make(chan result[Out], 1)
+			select {
+			case p.pending <- t.slot:
+			case <-p.ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case p.tasks <- t:
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// work processes tasks until the tasks channel is closed or the worker is
+// removed.  It runs in a dedicated goroutine for each worker.
+func (p *Pool[In, Out]) work(w *worker) {
+	defer p.wg.Done()
+	defer p.removeWorker(w)
+	for {
+		select {
+		case t, ok := <-p.tasks:
+			if !ok {
+				return
+			}
+			res := p.process(w, t.item)
+			if p.ordered {
+				t.slot <- res
+			} else if res.valid {
+				p.send(res.value)
+			}
+		case <-w.alive:
+		case <-w.quit:
+			return
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// removeWorker removes an exited worker from the running workers, if it has
+// not already been removed by Resize, so that it is no longer checked for
+// liveness.
+func (p *Pool[In, Out]) removeWorker(w *worker) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	close(w.exited)
+	for i, other := range p.workers {
+		if other == w {
+			p.workers = append(p.workers[:i:i], p.workers[i+1:]...)
+			return
+		}
+	}
+}
+
+// process calls fn for a single item, recovering from any panic.  A panic
+// drops the item, and marks the pool as degraded until the liveness monitor
+// next finds no new dropped items.
+func (p *Pool[In, Out]) process(w *worker, item In) (res result[Out]) {
+	defer func() {
+		if r := recover(); r != nil {
+			p.mu.Lock()
+			p.lastPanic = fmt.Sprintf("worker %d panicked: %v", w.id, r)
+			p.mu.Unlock()
+			atomic.AddUint64(&p.dropped, 1)
+
+			stack := string(debug.Stack())
+			if p.log != nil {
+				p.log.Error(fmt.Sprintf("worker %d failed: panicked: %v\n", w.id, r), stack)
+			}
+			if p.healthHandle != nil {
+				p.healthHandle.RecordStack(stack)
+				p.reportDropped()
+			}
+		}
+	}()
+	return result[Out]{value: p.fn(p.ctx, item), valid: true}
+}
+
+// reportDropped reports the pool as degraded due to dropped items.
+func (p *Pool[In, Out]) reportDropped() {
+	p.mu.Lock()
+	lastPanic := p.lastPanic
+	p.mu.Unlock()
+	p.healthHandle.SetDegraded(fmt.Sprintf("%s (%d items dropped)", lastPanic, p.Dropped()))
+}
+
+// emit sends the results of an Ordered pool to the output, in input order.  It
+// runs in a dedicated goroutine.
+func (p *Pool[In, Out]) emit() {
+	defer p.wg.Done()
+	for slot := range p.pending {
+		select {
+		case res := <-slot:
+			if res.valid {
+				p.send(res.value)
+			}
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// send sends a result to the output, if any.  Results that cannot be sent
+// because the pool is stopping, or the output is closed, are dropped.
+func (p *Pool[In, Out]) send(value Out) {
+	if p.out != nil {
+		_ = p.out.Send(p.ctx, value)
+	}
+}
+
+// monitorLiveness checks the liveness of each worker, every livenessPeriod,
+// until stopMonitor is closed.  It runs in a dedicated goroutine.
+func (p *Pool[In, Out]) monitorLiveness() {
+	defer close(p.monitorStopped)
+	tkr := time.NewTicker(p.healthHandle.LivenessPeriod(p.livenessPeriod))
+	defer tkr.Stop()
+	ready := false
+	lastDropped := uint64(0)
+	for {
+		select {
+		case <-p.stopMonitor:
+			return
+		case <-tkr.C:
+		}
+
+		if !p.checkLiveness(tkr.C) {
+			return
+		}
+
+		// the pool remains degraded for a period after an item is dropped
+		dropped := p.Dropped()
+		if dropped == lastDropped {
+			p.healthHandle.SetHealthy()
+			p.healthHandle.ClearStack()
+		} else {
+			p.reportDropped()
+		}
+		lastDropped = dropped
+
+		// the workers have all read from their channels, so they are ready
+		if !ready {
+			p.healthHandle.SetReady()
+			ready = true
+		}
+	}
+}
+
+// livenessCheck is the outcome of offering a tick to a worker.
+type livenessCheck struct {
+	w *worker
+
+	// read is true if the worker read the tick, and latency is the time it
+	// took to do so
+	read    bool
+	latency time.Duration
+}
+
+// checkLiveness offers a tick to each running worker, as the actor liveness
+// monitor does, and waits until each has read it or exited, recording each
+// worker's latency.  Each tick from ticks that arrives while waiting is a
+// missed tick, and marks the pool as unhealthy.  This returns false if
+// stopMonitor is closed while waiting.
+func (p *Pool[In, Out]) checkLiveness(ticks <-chan time.Time) bool {
+	p.mu.Lock()
+	workers := append([]*worker{}, p.workers...)
+	p.mu.Unlock()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	checks := make(chan livenessCheck, len(workers))
+	offered := time.Now()
+	for _, w := range workers {
+		go func(w *worker) {
+			select {
+			case w.alive <- struct{}{}:
+				checks <- livenessCheck{w: w, read: true, latency: time.Since(offered)}
+			case <-w.exited:
+				checks <- livenessCheck{w: w}
+			case <-stop:
+			}
+		}(w)
+	}
+
+	waiting := map[*worker]struct{}{}
+	for _, w := range workers {
+		waiting[w] = struct{}{}
+	}
+	misses := 0
+	for len(waiting) > 0 {
+		select {
+		case <-p.stopMonitor:
+			return false
+		case c := <-checks:
+			delete(waiting, c.w)
+			if c.read {
+				p.healthHandle.RecordLiveness(c.latency)
+			}
+		case <-ticks:
+			misses++
+			p.healthHandle.RecordMissedTick()
+			ids := make([]int, 0, len(waiting))
+			for w := range waiting {
+				ids = append(ids, w.id)
+			}
+			p.healthHandle.SetUnhealthy(livenessMessage(misses, ids))
+		}
+	}
+	return true
+}
+
+// livenessMessage builds the health message for workers that have missed
+// liveness checks.
+func livenessMessage(misses int, ids []int) string {
+	sort.Ints(ids)
+	names := make([]string, len(ids))
+	for i, id := range ids {
+		names[i] = fmt.Sprint(id)
+	}
+	message := fmt.Sprintf("health check timed out for worker(s) %s", strings.Join(names, ", "))
+	if misses > 1 {
+		message = fmt.Sprintf("%s (%d ticks missed)", message, misses)
+	}
+	return message
+}
@@ -0,0 +1,332 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package chanworkers
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/config"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/health"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/log"
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/telemetry"
+	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/comptest"
+	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/pipe"
+	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/startup"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+)
+
+// double doubles its input, after a short random delay.
+func double(_ context.Context, i int) int {
+	time.Sleep(time.Duration(rand.Intn(100)) * time.Microsecond)
+	return i * 2
+}
+
+// collect reads n items from the pipe.
+func collect(t *testing.T, out *pipe.Pipe[int], n int) []int {
+	got := make([]int, 0, n)
+	for len(got) < n {
+		select {
+		case v := <-out.Chan():
+			got = append(got, v)
+		case <-time.After(time.Second):
+			require.FailNow(t, "timed out waiting for results", "got %v", got)
+		}
+	}
+	return got
+}
+
+func TestUnordered(t *testing.T) {
+	in := make(chan int)
+	out := pipe.New[int](10)
+	pool := New[int, int](in, out, double, WithWorkers(4))
+	pool.Start()
+
+	go func() {
+		for i := 0; i < 100; i++ {
+			in <- i
+		}
+	}()
+	got := collect(t, out, 100)
+	require.NoError(t, pool.Stop(context.Background()))
+
+	sort.Ints(got)
+	for i, v := range got {
+		require.Equal(t, i*2, v)
+	}
+}
+
+func TestOrdered(t *testing.T) {
+	in := make(chan int)
+	out := pipe.New[int](10)
+	pool := New[int, int](in, out, double, WithWorkers(4), Ordered())
+	pool.Start()
+
+	go func() {
+		for i := 0; i < 100; i++ {
+			in <- i
+		}
+	}()
+	got := collect(t, out, 100)
+	require.NoError(t, pool.Stop(context.Background()))
+
+	for i, v := range got {
+		require.Equal(t, i*2, v)
+	}
+}
+
+func TestResize(t *testing.T) {
+	in := make(chan int)
+	release := make(chan struct{})
+	var running int32
+	var peak int32
+	block := func(_ context.Context, i int) int {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		<-release
+		atomic.AddInt32(&running, -1)
+		return i
+	}
+	pool := New[int, int](in, nil, block, WithWorkers(2))
+	pool.Start()
+
+	// each of the two workers takes an item
+	in <- 1
+	in <- 2
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&running) == 2 }, time.Second, time.Millisecond)
+
+	// growing the pool lets more items run concurrently
+	pool.Resize(4)
+	require.Equal(t, 4, pool.Size())
+	in <- 3
+	in <- 4
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&running) == 4 }, time.Second, time.Millisecond)
+
+	// shrinking the pool stops workers once their items are done
+	pool.Resize(1)
+	close(release)
+	require.Eventually(t, func() bool {
+		pool.mu.Lock()
+		defer pool.mu.Unlock()
+		return len(pool.workers) == 1
+	}, time.Second, time.Millisecond)
+	require.Equal(t, int32(4), atomic.LoadInt32(&peak))
+
+	require.NoError(t, pool.Stop(context.Background()))
+}
+
+func TestDrain(t *testing.T) {
+	in := make(chan int, 10)
+	out := pipe.New[int](10)
+	pool := New[int, int](in, out, double, WithWorkers(2), Ordered())
+
+	// items queued before stopping are processed
+	for i := 0; i < 10; i++ {
+		in <- i
+	}
+	pool.Start()
+	require.NoError(t, pool.Stop(context.Background()))
+
+	got := collect(t, out, 10)
+	for i, v := range got {
+		require.Equal(t, i*2, v)
+	}
+}
+
+func TestStopTimeout(t *testing.T) {
+	in := make(chan int, 1)
+	cancelled := make(chan struct{})
+	stuck := func(ctx context.Context, i int) int {
+		<-ctx.Done()
+		close(cancelled)
+		return i
+	}
+	pool := New[int, int](in, nil, stuck, WithWorkers(1))
+	pool.MonitorLiveness(health.NewRegistration("test-pool").Handle, time.Millisecond)
+	in <- 1
+	pool.Start()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, pool.Stop(ctx), context.DeadlineExceeded)
+
+	// the liveness monitor has stopped, even though the pool had not
+	select {
+	case <-pool.monitorStopped:
+	default:
+		require.Fail(t, "liveness monitor is still running")
+	}
+
+	// the func's context is cancelled when draining times out
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		require.Fail(t, "func context was not cancelled")
+	}
+}
+
+func TestPanic(t *testing.T) {
+	in := make(chan int)
+	out := pipe.New[int](10)
+	fn := func(_ context.Context, i int) int {
+		if i == 1 {
+			panic("uhoh")
+		}
+		return i
+	}
+	pool := New[int, int](in, out, fn, WithWorkers(1), Ordered())
+	pool.Start()
+
+	// the panicking item is dropped, and the worker continues
+	in <- 1
+	in <- 2
+	require.Equal(t, []int{2}, collect(t, out, 1))
+	require.NoError(t, pool.Stop(context.Background()))
+	require.Equal(t, uint64(1), pool.Dropped())
+}
+
+func TestPanicHealth(t *testing.T) {
+	reg := health.NewRegistration("test-pool")
+	in := make(chan int)
+	fn := func(_ context.Context, i int) int {
+		if i == 1 {
+			panic("uhoh")
+		}
+		return i
+	}
+	var h health.Component
+	comptest.FxTest(t,
+		fx.Supply(core.BundleParams{AutoStart: startup.Never}),
+		health.Module,
+		log.Module,
+		config.MockModule,
+		fx.Supply(reg),
+		fx.Invoke(func(lc fx.Lifecycle) {
+			pool := New[int, int](in, nil, fn, WithWorkers(1))
+			pool.MonitorLiveness(reg.Handle, 50*time.Millisecond)
+			pool.HookLifecycle(lc)
+		}),
+		fx.Populate(&h),
+	).WithRunningApp(func() {
+		require.Eventually(t, func() bool {
+			return h.GetHealth()["test-pool"].State == health.Ready
+		}, time.Second, time.Millisecond)
+
+		// a panic degrades the pool
+		in <- 1
+		require.Eventually(t, func() bool {
+			return h.GetHealth()["test-pool"].Status == health.Degraded
+		}, time.Second, time.Millisecond)
+		require.Equal(t, "worker 0 panicked: uhoh (1 items dropped)", h.GetHealth()["test-pool"].Message)
+
+		// until the pool runs without dropping items
+		require.Eventually(t, func() bool {
+			return h.GetHealth()["test-pool"].Status == health.Healthy
+		}, time.Second, time.Millisecond)
+	})
+}
+
+func TestLiveness(t *testing.T) {
+	reg := health.NewRegistration("test-pool")
+	in := make(chan int)
+	unblock := make(chan struct{})
+	fn := func(_ context.Context, i int) int {
+		if i == 1 {
+			<-unblock
+		}
+		return i
+	}
+	var h health.Component
+	var tel telemetry.Component
+	comptest.FxTest(t,
+		fx.Supply(core.BundleParams{AutoStart: startup.Never}),
+		health.Module,
+		log.Module,
+		config.MockModule,
+		telemetry.Module,
+		fx.Supply(reg),
+		fx.Invoke(func(lc fx.Lifecycle) {
+			pool := New[int, int](in, nil, fn, WithWorkers(2))
+			pool.MonitorLiveness(reg.Handle, time.Millisecond)
+			pool.HookLifecycle(lc)
+		}),
+		fx.Populate(&h),
+		fx.Populate(&tel),
+	).WithRunningApp(func() {
+		require.Eventually(t, func() bool {
+			return h.GetHealth()["test-pool"].State == health.Ready
+		}, time.Second, time.Millisecond)
+
+		// one worker gets stuck
+		in <- 1
+		require.Eventually(t, func() bool {
+			return strings.Contains(h.GetHealth()["test-pool"].Message, "ticks missed")
+		}, time.Second, time.Millisecond)
+		require.Equal(t, health.Unhealthy, h.GetHealth()["test-pool"].Status)
+		require.Regexp(t, `^health check timed out for worker\(s\) \d \(\d+ ticks missed\)$`, h.GetHealth()["test-pool"].Message)
+
+		// and recovers
+		close(unblock)
+		require.Eventually(t, func() bool {
+			return h.GetHealth()["test-pool"].Status == health.Healthy
+		}, time.Second, time.Millisecond)
+
+		var bldr strings.Builder
+		require.NoError(t, tel.WriteText(&bldr))
+		require.Contains(t, bldr.String(), `liveness_ticks_missed{component="test-pool"}`)
+		require.Contains(t, bldr.String(), `liveness_latency_seconds_count{component="test-pool"}`)
+	})
+}
+
+func TestLivenessAfterInputClosed(t *testing.T) {
+	reg := health.NewRegistration("test-pool")
+	in := make(chan int)
+	var pool *Pool[int, int]
+	var h health.Component
+	comptest.FxTest(t,
+		fx.Supply(core.BundleParams{AutoStart: startup.Never}),
+		health.Module,
+		log.Module,
+		config.MockModule,
+		fx.Supply(reg),
+		fx.Invoke(func(lc fx.Lifecycle) {
+			pool = New[int, int](in, nil, double, WithWorkers(2))
+			pool.MonitorLiveness(reg.Handle, time.Millisecond)
+			pool.HookLifecycle(lc)
+		}),
+		fx.Populate(&h),
+	).WithRunningApp(func() {
+		require.Eventually(t, func() bool {
+			return h.GetHealth()["test-pool"].State == health.Ready
+		}, time.Second, time.Millisecond)
+
+		// once the input is closed, the workers exit, and are no longer
+		// checked
+		close(in)
+		require.Eventually(t, func() bool {
+			pool.mu.Lock()
+			defer pool.mu.Unlock()
+			return len(pool.workers) == 0
+		}, time.Second, time.Millisecond)
+
+		for i := 0; i < 20; i++ {
+			time.Sleep(time.Millisecond)
+			require.Equal(t, health.Healthy, h.GetHealth()["test-pool"].Status)
+		}
+	})
+}
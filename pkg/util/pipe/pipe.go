@@ -0,0 +1,250 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package pipe provides typed, bounded queues for high-throughput data flow
+// between components, such as payloads moving through the trace pipeline.
+// Unlike pkg/util/subscriptions, which is meant for infrequent events, a Pipe
+// carries data from any number of senders to a single consuming actor.
+//
+// A Pipe wraps a buffered channel.  Senders call Send, which waits for room in
+// the pipe until its context is done, or TrySend, which does not wait.  The
+// consumer reads from Chan in its event loop, and may use Batch to take
+// any further items that are already waiting.  A pipe can be closed, after
+// which sends fail with ErrClosed, while items already in the pipe remain
+// available to the consumer.
+//
+// A pipe tracks the sends that had to wait for room (backpressure) and the
+// sends that failed.  With WithTelemetry, it reports these, along with its
+// queue depth, as telemetry.
+//
+// Components typically expose only the Sender interface of a pipe they
+// consume.
+//
+// All Pipe methods can be called concurrently.
+package pipe
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/telemetry"
+)
+
+// ErrClosed is returned when sending to a closed pipe.
+var ErrClosed = errors.New("pipe is closed")
+
+// Sender is the sending side of a Pipe.
+type Sender[T any] interface {
+	// Send sends an item, waiting until there is room in the pipe.  It returns
+	// the context's error if the context is done first, or ErrClosed if the
+	// pipe is closed.
+	Send(ctx context.Context, item T) error
+
+	// TrySend sends an item if there is room in the pipe, returning false if
+	// the item was not sent.
+	TrySend(item T) bool
+}
+
+// Pipe is a typed, bounded queue.
+type Pipe[T any] struct {
+	ch chan T
+
+	// closed is closed when the pipe is closed
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	// sent, blocked, and failed count sends, and are accessed atomically
+	sent    uint64
+	blocked uint64
+	failed  uint64
+
+	// telemetry metrics, if enabled
+	blockedCounter telemetry.Counter
+	waitHistogram  telemetry.Histogram
+	failedCounter  telemetry.Counter
+}
+
+// Option configures a Pipe.
+type Option func(*options)
+
+type options struct {
+	telemetry telemetry.Component
+	subsystem string
+}
+
+// WithTelemetry reports the pipe's metrics to the telemetry component, under
+// the given subsystem: `<subsystem>_queue_depth`, `<subsystem>_send_blocked`,
+// `<subsystem>_send_wait_seconds`, and `<subsystem>_send_failed` (tagged by
+// reason: "canceled", "closed", or "full").
+func WithTelemetry(telemetry telemetry.Component, subsystem string) Option {
+	return func(o *options) {
+		o.telemetry = telemetry
+		o.subsystem = subsystem
+	}
+}
+
+// Stats contains statistics for a Pipe.
+type Stats struct {
+	// Sent is the number of items sent.
+	Sent uint64 `json:"sent"`
+
+	// Blocked is the number of sends that waited for room in the pipe.
+	Blocked uint64 `json:"blocked"`
+
+	// Failed is the number of sends that failed.
+	Failed uint64 `json:"failed"`
+
+	// Queued is the number of items in the pipe.
+	Queued int `json:"queued"`
+
+	// Capacity is the capacity of the pipe.
+	Capacity int `json:"capacity"`
+}
+
+// New creates a new Pipe with the given capacity, which must be positive.
+func New[T any](capacity int, opts ...Option) *Pipe[T] {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	p := &Pipe[T]{
+		ch:     make(chan T, capacity),
+		closed: make(chan struct{}),
+	}
+
+	if o.telemetry != nil {
+		o.telemetry.NewGaugeFunc(o.subsystem, "queue_depth", "Items waiting in the queue",
+			func() float64 { return float64(len(p.ch)) })
+		p.blockedCounter = o.telemetry.NewCounter(o.subsystem, "send_blocked", nil,
+			"Sends that waited for room in the queue")
+		p.waitHistogram = o.telemetry.NewHistogram(o.subsystem, "send_wait_seconds", nil,
+			"Time spent waiting for room in the queue", nil)
+		p.failedCounter = o.telemetry.NewCounter(o.subsystem, "send_failed", []string{"reason"},
+			"Sends that failed")
+	}
+
+	return p
+}
+
+// Send implements Sender#Send.
+func (p *Pipe[T]) Send(ctx context.Context, item T) error {
+	select {
+	case <-p.closed:
+		p.fail("closed")
+		return ErrClosed
+	default:
+	}
+
+	// try without waiting, first
+	select {
+	case p.ch <- item:
+		atomic.AddUint64(&p.sent, 1)
+		return nil
+	default:
+	}
+
+	atomic.AddUint64(&p.blocked, 1)
+	if p.blockedCounter != nil {
+		p.blockedCounter.Inc()
+	}
+	start := time.Now()
+	defer func() {
+		if p.waitHistogram != nil {
+			p.waitHistogram.Observe(time.Since(start).Seconds())
+		}
+	}()
+
+	select {
+	case p.ch <- item:
+		atomic.AddUint64(&p.sent, 1)
+		return nil
+	case <-ctx.Done():
+		p.fail("canceled")
+		return ctx.Err()
+	case <-p.closed:
+		p.fail("closed")
+		return ErrClosed
+	}
+}
+
+// TrySend implements Sender#TrySend.
+func (p *Pipe[T]) TrySend(item T) bool {
+	select {
+	case <-p.closed:
+		p.fail("closed")
+		return false
+	default:
+	}
+
+	select {
+	case p.ch <- item:
+		atomic.AddUint64(&p.sent, 1)
+		return true
+	default:
+		p.fail("full")
+		return false
+	}
+}
+
+// fail records a failed send.
+func (p *Pipe[T]) fail(reason string) {
+	atomic.AddUint64(&p.failed, 1)
+	if p.failedCounter != nil {
+		p.failedCounter.Inc(reason)
+	}
+}
+
+// Chan gets the channel from which the consumer reads items.  This channel
+// is never closed.
+func (p *Pipe[T]) Chan() <-chan T {
+	return p.ch
+}
+
+// Batch returns a batch of up to max items: first, typically just received
+// from Chan, followed by any items already waiting in the pipe.  It does not
+// wait for more items.  A max of less than 1 is treated as 1.
+func (p *Pipe[T]) Batch(first T, max int) []T {
+	if max < 1 {
+		max = 1
+	}
+	batch := make([]T, 1, max)
+	batch[0] = first
+	for len(batch) < max {
+		select {
+		case item := <-p.ch:
+			batch = append(batch, item)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+// Close closes the pipe, causing further sends, including those waiting for
+// room, to fail with ErrClosed.  Items already in the pipe remain available
+// from Chan.  It is safe to call more than once.
+func (p *Pipe[T]) Close() {
+	p.closeOnce.Do(func() { close(p.closed) })
+}
+
+// Len returns the number of items waiting in the pipe.
+func (p *Pipe[T]) Len() int {
+	return len(p.ch)
+}
+
+// Stats gets the pipe's statistics.
+func (p *Pipe[T]) Stats() Stats {
+	return Stats{
+		Sent:     atomic.LoadUint64(&p.sent),
+		Blocked:  atomic.LoadUint64(&p.blocked),
+		Failed:   atomic.LoadUint64(&p.failed),
+		Queued:   len(p.ch),
+		Capacity: cap(p.ch),
+	}
+}
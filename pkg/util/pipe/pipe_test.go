@@ -0,0 +1,124 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package pipe
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DataDog/dd-agent-comp-experiments/comp/core/telemetry"
+	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/comptest"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+)
+
+func TestSendReceive(t *testing.T) {
+	p := New[int](2)
+	require.NoError(t, p.Send(context.Background(), 1))
+	require.True(t, p.TrySend(2))
+	require.False(t, p.TrySend(3))
+
+	require.Equal(t, 1, <-p.Chan())
+	require.Equal(t, 2, <-p.Chan())
+	require.Equal(t, Stats{Sent: 2, Failed: 1, Capacity: 2}, p.Stats())
+}
+
+func TestSendBlocks(t *testing.T) {
+	p := New[int](1)
+	require.NoError(t, p.Send(context.Background(), 1))
+
+	// a full pipe applies backpressure until the consumer reads
+	sent := make(chan error)
+	go func() { sent <- p.Send(context.Background(), 2) }()
+	require.Eventually(t, func() bool { return p.Stats().Blocked == 1 }, time.Second, time.Millisecond)
+	require.Equal(t, 1, <-p.Chan())
+	require.NoError(t, <-sent)
+	require.Equal(t, 2, <-p.Chan())
+
+	// or until the context is done
+	require.NoError(t, p.Send(context.Background(), 3))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, p.Send(ctx, 4), context.DeadlineExceeded)
+	require.Equal(t, Stats{Sent: 3, Blocked: 2, Failed: 1, Queued: 1, Capacity: 1}, p.Stats())
+}
+
+func TestClose(t *testing.T) {
+	p := New[int](1)
+	require.NoError(t, p.Send(context.Background(), 1))
+
+	sent := make(chan error)
+	go func() { sent <- p.Send(context.Background(), 2) }()
+	require.Eventually(t, func() bool { return p.Stats().Blocked == 1 }, time.Second, time.Millisecond)
+
+	// closing fails waiting and later sends, but keeps queued items
+	p.Close()
+	require.ErrorIs(t, <-sent, ErrClosed)
+	require.ErrorIs(t, p.Send(context.Background(), 3), ErrClosed)
+	require.False(t, p.TrySend(4))
+	require.Equal(t, 1, <-p.Chan())
+	p.Close()
+}
+
+func TestBatch(t *testing.T) {
+	p := New[int](10)
+	for i := 1; i <= 5; i++ {
+		require.True(t, p.TrySend(i))
+	}
+
+	require.Equal(t, []int{1, 2, 3}, p.Batch(<-p.Chan(), 3))
+	require.Equal(t, []int{4, 5}, p.Batch(<-p.Chan(), 3))
+	require.Equal(t, []int{6}, p.Batch(6, 0))
+}
+
+func TestConcurrentSenders(t *testing.T) {
+	p := New[int](4)
+
+	var wg sync.WaitGroup
+	for s := 0; s < 10; s++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				p.Send(context.Background(), i)
+			}
+		}()
+	}
+
+	received := 0
+	for received < 1000 {
+		received += len(p.Batch(<-p.Chan(), 16))
+	}
+	wg.Wait()
+	require.Equal(t, uint64(1000), p.Stats().Sent)
+}
+
+func TestTelemetry(t *testing.T) {
+	var tel telemetry.Component
+	comptest.FxTest(t,
+		telemetry.Module,
+		fx.Populate(&tel),
+	).WithRunningApp(func() {
+		p := New[int](1, WithTelemetry(tel, "test"))
+		require.True(t, p.TrySend(1))
+		require.False(t, p.TrySend(2))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		require.Error(t, p.Send(ctx, 3))
+
+		var bldr strings.Builder
+		require.NoError(t, tel.WriteText(&bldr))
+		text := bldr.String()
+		require.Contains(t, text, "test_queue_depth 1\n")
+		require.Contains(t, text, "test_send_blocked 1\n")
+		require.Contains(t, text, "test_send_wait_seconds_count 1\n")
+		require.Contains(t, text, `test_send_failed{reason="full"} 1`)
+		require.Contains(t, text, `test_send_failed{reason="canceled"} 1`)
+	})
+}
@@ -0,0 +1,56 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package pipe
+
+import "context"
+
+// Request carries a request to an actor, along with the means to respond.
+//
+// A component that answers requests in its actor goroutine creates a
+// Pipe[*Request[Req, Resp]], reads requests from it in its event loop, and
+// calls Respond for each.  Callers use Call.
+type Request[Req, Resp any] struct {
+	// Req is the request.
+	Req Req
+
+	// respCh carries the response, and has capacity 1 so that Respond never
+	// blocks
+	respCh chan response[Resp]
+}
+
+// response is a response to a Request
+type response[Resp any] struct {
+	resp Resp
+	err  error
+}
+
+// Respond sends the response to the caller.  It must be called exactly once
+// for each request, and does not block, even if the caller has given up.
+func (r *Request[Req, Resp]) Respond(resp Resp, err error) {
+	r.respCh <- response[Resp]{resp, err}
+}
+
+// Call sends a request via the given sender and waits for the response.  It
+// returns the context's error if the context is done before the request is
+// sent or the response arrives, or ErrClosed if the pipe is closed.
+func Call[Req, Resp any](ctx context.Context, s Sender[*Request[Req, Resp]], req Req) (Resp, error) {
+	var zero Resp
+
+	r := &Request[Req, Resp]{
+		Req:    req,
+		respCh: make(chan response[Resp], 1),
+	}
+	if err := s.Send(ctx, r); err != nil {
+		return zero, err
+	}
+
+	select {
+	case res := <-r.respCh:
+		return res.resp, res.err
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
@@ -0,0 +1,62 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package pipe
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCall(t *testing.T) {
+	requests := New[*Request[int, int]](1)
+
+	// an actor that doubles numbers, and refuses negative numbers
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for {
+			select {
+			case r := <-requests.Chan():
+				if r.Req < 0 {
+					r.Respond(0, errors.New("negative"))
+				} else {
+					r.Respond(r.Req*2, nil)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	resp, err := Call[int, int](context.Background(), requests, 21)
+	require.NoError(t, err)
+	require.Equal(t, 42, resp)
+
+	_, err = Call[int, int](context.Background(), requests, -1)
+	require.EqualError(t, err, "negative")
+}
+
+func TestCallTimeout(t *testing.T) {
+	// nothing reads requests, so the call times out waiting for a response
+	requests := New[*Request[string, string]](1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := Call[string, string](ctx, requests, "hello")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// a late response does not block the actor
+	r := <-requests.Chan()
+	r.Respond("too late", nil)
+
+	// a closed pipe fails immediately
+	requests.Close()
+	_, err = Call[string, string](context.Background(), requests, "hello")
+	require.ErrorIs(t, err, ErrClosed)
+}
@@ -0,0 +1,385 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package subscriptions
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Policy determines how messages are delivered to a receiver that is not
+// keeping up with them.
+type Policy int
+
+const (
+	// Block waits until the receiver has room in its buffer.  A receiver that
+	// fails to read stalls the transmitter, and with it every other receiver.
+	// This is the default.
+	Block Policy = iota
+
+	// DropOldest discards the oldest buffered message to make room for the
+	// new one.  This is appropriate when only the most recent messages matter.
+	DropOldest
+
+	// DropNewest discards the new message if the receiver's buffer is full.
+	DropNewest
+
+	// Unbounded queues messages without limit, so the transmitter never
+	// waits and no message is lost.  The receiver is considered to be falling
+	// behind when the queue exceeds its high-water mark.
+	Unbounded
+)
+
+var policyNames = []string{"block", "drop-oldest", "drop-newest", "unbounded"}
+
+// String implements fmt.Stringer.
+func (p Policy) String() string {
+	if p < 0 || int(p) >= len(policyNames) {
+		return fmt.Sprintf("Policy(%d)", int(p))
+	}
+	return policyNames[p]
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (p Policy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+const (
+	// defaultBufferSize is the default size of a receiver's channel buffer.
+	defaultBufferSize = 1
+
+	// defaultHighWaterMark is the default high-water mark for receivers with
+	// the Unbounded policy.
+	defaultHighWaterMark = 1000
+)
+
+// Option configures a receiver.
+type Option func(*options)
+
+type options struct {
+	subscriber    string
+	policy        Policy
+	bufferSize    int
+	highWaterMark int
+}
+
+// WithSubscriber names the receiving component (typically its package path),
+// for monitoring.  NewSubscription sets this automatically.
+func WithSubscriber(subscriber string) Option {
+	return func(o *options) { o.subscriber = subscriber }
+}
+
+// WithPolicy sets the receiver's delivery policy.
+func WithPolicy(policy Policy) Option {
+	return func(o *options) { o.policy = policy }
+}
+
+// WithBufferSize sets the size of the receiver's channel buffer, which
+// defaults to 1.  With the DropOldest and DropNewest policies, this is the
+// number of messages retained for a receiver that is not reading.
+func WithBufferSize(size int) Option {
+	return func(o *options) { o.bufferSize = size }
+}
+
+// WithHighWaterMark sets the number of queued messages above which a receiver
+// with the Unbounded policy is considered to be falling behind.  This defaults
+// to 1000.
+func WithHighWaterMark(mark int) Option {
+	return func(o *options) { o.highWaterMark = mark }
+}
+
+// Stats contains delivery statistics for a receiver.
+type Stats struct {
+	// Policy is the receiver's delivery policy.
+	Policy Policy `json:"policy"`
+
+	// Delivered is the number of messages delivered to the receiver's
+	// channel or queued for it.
+	Delivered uint64 `json:"delivered"`
+
+	// Dropped is the number of messages discarded due to the receiver's
+	// policy.
+	Dropped uint64 `json:"dropped"`
+
+	// Queued is the number of messages waiting to be read.
+	Queued int `json:"queued"`
+
+	// PeakQueued is the largest number of messages that have waited to be
+	// read at once.  This is only tracked with the Unbounded policy.
+	PeakQueued int `json:"peak_queued"`
+
+	// HighWaterMark is the receiver's high-water mark, with the Unbounded
+	// policy, or zero.
+	HighWaterMark int `json:"high_water_mark"`
+
+	// HighWaterCrossings is the number of times the number of queued messages
+	// has risen above the high-water mark.
+	HighWaterCrossings uint64 `json:"high_water_crossings"`
+}
+
+// receiverState is the shared state of a Receiver.
+type receiverState[M Message] struct {
+	// ch is the channel from which the subscriber reads.
+	ch chan M
+
+	// subscriber is the name of the receiving component, if known
+	subscriber string
+
+	// policy is the delivery policy
+	policy Policy
+
+	// highWaterMark is the high-water mark for the Unbounded policy
+	highWaterMark int
+
+	// delivered and dropped count messages, and are accessed atomically
+	delivered uint64
+	dropped   uint64
+
+	// done is closed when the receiver is unsubscribed, after which no
+	// further messages are delivered
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// Mutex covers the remaining fields, used with the Unbounded policy and
+	// while replaying messages to a new receiver
+	sync.Mutex
+
+	// overflow holds messages that did not fit in ch, or that are waiting
+	// behind replayed messages, oldest first
+	overflow []M
+
+	// pumping is true while a goroutine is moving messages from overflow to
+	// ch
+	pumping bool
+
+	// inFlight is true while that goroutine holds a message, having removed
+	// it from overflow but not yet sent it to ch
+	inFlight bool
+
+	// peakQueued is the largest observed number of queued messages
+	peakQueued int
+
+	// highWaterCrossings counts the times the queue has risen above the
+	// high-water mark
+	highWaterCrossings uint64
+
+	// replayed is closed once replayed messages have been delivered to a
+	// receiver with the Block policy, and is nil when no replay is pending
+	replayed chan struct{}
+}
+
+// newReceiverState creates the state for a new Receiver with the given options.
+func newReceiverState[M Message](opts []Option) *receiverState[M] {
+	o := options{
+		policy:        Block,
+		bufferSize:    defaultBufferSize,
+		highWaterMark: defaultHighWaterMark,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.bufferSize < 1 {
+		o.bufferSize = 1
+	}
+
+	r := &receiverState[M]{
+		ch:         make(chan M, o.bufferSize),
+		subscriber: o.subscriber,
+		policy:     o.policy,
+		done:       make(chan struct{}),
+	}
+	if o.policy == Unbounded {
+		r.highWaterMark = o.highWaterMark
+	}
+	return r
+}
+
+// send delivers a message according to the receiver's policy.
+func (r *receiverState[M]) send(message M) {
+	select {
+	case <-r.done:
+		return
+	default:
+	}
+
+	// while replayed messages are pending, wait behind them to preserve order
+	if r.policy == Block && !r.waitForReplay() {
+		return
+	}
+
+	switch r.policy {
+	case DropNewest:
+		select {
+		case r.ch <- message:
+			atomic.AddUint64(&r.delivered, 1)
+		default:
+			atomic.AddUint64(&r.dropped, 1)
+		}
+
+	case DropOldest:
+		for {
+			select {
+			case r.ch <- message:
+				atomic.AddUint64(&r.delivered, 1)
+				return
+			default:
+			}
+
+			// make room, unless the receiver has just done so itself
+			select {
+			case <-r.ch:
+				atomic.AddUint64(&r.dropped, 1)
+			default:
+			}
+		}
+
+	case Unbounded:
+		r.enqueue(message)
+
+	default:
+		select {
+		case r.ch <- message:
+			atomic.AddUint64(&r.delivered, 1)
+		case <-r.done:
+		}
+	}
+}
+
+// close stops delivery of messages to the receiver.  It is safe to call more
+// than once.
+func (r *receiverState[M]) close() {
+	r.closeOnce.Do(func() { close(r.done) })
+}
+
+// enqueue delivers a message under the Unbounded policy.  Messages that do not
+// fit in the channel are held in overflow, and a goroutine moves them to the
+// channel, in order, as the subscriber reads.
+func (r *receiverState[M]) enqueue(message M) {
+	r.Lock()
+	defer r.Unlock()
+	atomic.AddUint64(&r.delivered, 1)
+
+	// if nothing is waiting, try to deliver directly
+	if !r.pumping {
+		select {
+		case r.ch <- message:
+			return
+		default:
+		}
+	}
+
+	r.overflow = append(r.overflow, message)
+	queued := len(r.ch) + len(r.overflow)
+	if r.inFlight {
+		queued++
+	}
+	if queued > r.peakQueued {
+		r.peakQueued = queued
+	}
+	if queued == r.highWaterMark+1 {
+		r.highWaterCrossings++
+	}
+	if !r.pumping {
+		r.pumping = true
+		go r.pump()
+	}
+}
+
+// replay delivers messages ahead of any later messages, according to the
+// receiver's policy.  It is used to bring a new receiver up to date with
+// existing state, and must be called before the receiver is added to a
+// transmitter.
+//
+// The caller cannot wait for a new receiver to read, so with the Block policy
+// the replayed messages are held and delivered as the receiver reads, and
+// later sends wait until they have all been delivered.  With other policies,
+// the messages are sent as usual.
+func (r *receiverState[M]) replay(messages []M) {
+	if r.policy != Block {
+		for _, message := range messages {
+			r.send(message)
+		}
+		return
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	atomic.AddUint64(&r.delivered, uint64(len(messages)))
+	r.overflow = append(r.overflow, messages...)
+	r.replayed = make(chan struct{})
+	r.pumping = true
+	go r.pump()
+}
+
+// waitForReplay waits until any replayed messages have been delivered,
+// returning false if the receiver was closed first.
+func (r *receiverState[M]) waitForReplay() bool {
+	r.Lock()
+	replayed := r.replayed
+	r.Unlock()
+
+	if replayed == nil {
+		return true
+	}
+	select {
+	case <-replayed:
+		return true
+	case <-r.done:
+		return false
+	}
+}
+
+// pump moves messages from overflow to the channel until overflow is empty.
+func (r *receiverState[M]) pump() {
+	var zero M
+	for {
+		r.Lock()
+		r.inFlight = false
+		if len(r.overflow) == 0 {
+			r.pumping = false
+			r.overflow = nil
+			if r.replayed != nil {
+				close(r.replayed)
+				r.replayed = nil
+			}
+			r.Unlock()
+			return
+		}
+		message := r.overflow[0]
+		r.overflow[0] = zero // allow garbage collection
+		r.overflow = r.overflow[1:]
+		r.inFlight = true
+		r.Unlock()
+
+		select {
+		case r.ch <- message:
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// stats gets the receiver's delivery statistics.  Queued may briefly count a
+// message twice as it is moved from overflow to the channel.
+func (r *receiverState[M]) stats() Stats {
+	r.Lock()
+	defer r.Unlock()
+	queued := len(r.ch) + len(r.overflow)
+	if r.inFlight {
+		queued++
+	}
+	return Stats{
+		Policy:             r.policy,
+		Delivered:          atomic.LoadUint64(&r.delivered),
+		Dropped:            atomic.LoadUint64(&r.dropped),
+		Queued:             queued,
+		PeakQueued:         r.peakQueued,
+		HighWaterMark:      r.highWaterMark,
+		HighWaterCrossings: r.highWaterCrossings,
+	}
+}
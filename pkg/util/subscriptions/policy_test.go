@@ -0,0 +1,84 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package subscriptions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDropNewest(t *testing.T) {
+	rx := NewReceiver[int](WithPolicy(DropNewest), WithBufferSize(2))
+	tx := NewTransmitter([]Receiver[int]{rx})
+
+	for i := 0; i < 5; i++ {
+		tx.Notify(i)
+	}
+	require.Equal(t, Stats{Policy: DropNewest, Delivered: 2, Dropped: 3, Queued: 2}, rx.Stats())
+	require.Equal(t, 0, <-rx.Chan())
+	require.Equal(t, 1, <-rx.Chan())
+}
+
+func TestDropOldest(t *testing.T) {
+	rx := NewReceiver[int](WithPolicy(DropOldest), WithBufferSize(2))
+	tx := NewTransmitter([]Receiver[int]{rx})
+
+	for i := 0; i < 5; i++ {
+		tx.Notify(i)
+	}
+	require.Equal(t, Stats{Policy: DropOldest, Delivered: 5, Dropped: 3, Queued: 2}, rx.Stats())
+	require.Equal(t, 3, <-rx.Chan())
+	require.Equal(t, 4, <-rx.Chan())
+}
+
+func TestUnbounded(t *testing.T) {
+	rx := NewReceiver[int](WithPolicy(Unbounded), WithHighWaterMark(10))
+	tx := NewTransmitter([]Receiver[int]{rx})
+
+	// the transmitter does not block, even though nothing is reading
+	for i := 0; i < 100; i++ {
+		tx.Notify(i)
+	}
+	stats := rx.Stats()
+	require.Equal(t, uint64(100), stats.Delivered)
+	require.Equal(t, uint64(0), stats.Dropped)
+	require.Equal(t, 100, stats.Queued)
+	require.Equal(t, 100, stats.PeakQueued)
+	require.Equal(t, 10, stats.HighWaterMark)
+	require.Equal(t, uint64(1), stats.HighWaterCrossings)
+
+	// messages arrive in order
+	for i := 0; i < 100; i++ {
+		require.Equal(t, i, <-rx.Chan())
+	}
+	require.Eventually(t, func() bool { return rx.Stats().Queued == 0 }, time.Second, time.Millisecond)
+	require.Equal(t, 100, rx.Stats().PeakQueued)
+}
+
+func TestMixedPolicies(t *testing.T) {
+	// a receiver that is not reading does not stall delivery to others
+	slow := NewReceiver[string](WithPolicy(DropNewest))
+	fast := NewReceiver[string]()
+	tx := NewTransmitter([]Receiver[string]{slow, fast})
+
+	for _, msg := range []string{"a", "b", "c"} {
+		tx.Notify(msg)
+		require.Equal(t, msg, <-fast.Chan())
+	}
+	require.Equal(t, "a", <-slow.Chan())
+	require.Equal(t, uint64(2), slow.Stats().Dropped)
+}
+
+func TestZeroReceiverStats(t *testing.T) {
+	require.Equal(t, Stats{}, Receiver[string]{}.Stats())
+}
+
+func TestPolicyString(t *testing.T) {
+	require.Equal(t, "drop-oldest", DropOldest.String())
+	require.Equal(t, "Policy(17)", Policy(17).String())
+}
@@ -0,0 +1,135 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package subscriptions
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Registry records the transmitters created with Publisher#Transmitter, so
+// that the connections between components can be inspected.  When a
+// *Registry is available in the Fx app (comp/core/subscriptionmon provides
+// one), Publisher uses it automatically.
+//
+// All Registry methods can be called concurrently.
+type Registry struct {
+	// Mutex covers all fields
+	sync.Mutex
+
+	// transmitters are the registered transmitters
+	transmitters []registered
+}
+
+// registered is implemented by transmitterState, for any message type.
+type registered interface {
+	publication() Publication
+	edges() []Edge
+}
+
+// Publication describes a publishing component.
+type Publication struct {
+	// Publisher is the name of the publishing component.
+	Publisher string `json:"publisher"`
+
+	// MessageType is the type of message it publishes.
+	MessageType string `json:"message_type"`
+}
+
+// Edge describes the connection between a publishing component and a
+// receiver.
+type Edge struct {
+	Publication
+
+	// Subscriber is the name of the receiving component, or empty if the
+	// receiver was not named.
+	Subscriber string `json:"subscriber"`
+
+	// Sent is the number of messages sent over this edge.
+	Sent uint64 `json:"sent"`
+
+	// Queued is the number of messages waiting to be read by the receiver.
+	// A receiver with several publishers has the same queue for each.
+	Queued int `json:"queued"`
+}
+
+// NewRegistry creates a new, empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// register adds a transmitter to the registry.
+func (r *Registry) register(t registered) {
+	r.Lock()
+	defer r.Unlock()
+	r.transmitters = append(r.transmitters, t)
+}
+
+// Publications gets all registered publications, sorted by publisher and
+// then by message type.
+func (r *Registry) Publications() []Publication {
+	r.Lock()
+	defer r.Unlock()
+
+	pubs := make([]Publication, 0, len(r.transmitters))
+	for _, t := range r.transmitters {
+		pubs = append(pubs, t.publication())
+	}
+	sort.Slice(pubs, func(i, j int) bool { return pubs[i].less(pubs[j]) })
+	return pubs
+}
+
+// Edges gets the current edges from all registered transmitters, sorted by
+// publisher, message type, and then subscriber.
+func (r *Registry) Edges() []Edge {
+	r.Lock()
+	defer r.Unlock()
+
+	edges := []Edge{}
+	for _, t := range r.transmitters {
+		edges = append(edges, t.edges()...)
+	}
+	sort.SliceStable(edges, func(i, j int) bool {
+		if edges[i].Publication != edges[j].Publication {
+			return edges[i].Publication.less(edges[j].Publication)
+		}
+		return edges[i].Subscriber < edges[j].Subscriber
+	})
+	return edges
+}
+
+// less orders publications by publisher and then by message type.
+func (p Publication) less(other Publication) bool {
+	if p.Publisher != other.Publisher {
+		return p.Publisher < other.Publisher
+	}
+	return p.MessageType < other.MessageType
+}
+
+// publication implements registered#publication.
+func (t *transmitterState[M]) publication() Publication {
+	return Publication{Publisher: t.publisher, MessageType: messageType[M]()}
+}
+
+// edges implements registered#edges.
+func (t *transmitterState[M]) edges() []Edge {
+	t.RLock()
+	links := t.links
+	t.RUnlock()
+
+	pub := t.publication()
+	edges := make([]Edge, 0, len(links))
+	for _, l := range links {
+		edges = append(edges, Edge{
+			Publication: pub,
+			Subscriber:  l.r.subscriber,
+			Sent:        atomic.LoadUint64(&l.sent),
+			Queued:      l.r.stats().Queued,
+		})
+	}
+	return edges
+}
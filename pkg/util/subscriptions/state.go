@@ -0,0 +1,200 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package subscriptions
+
+import "sync"
+
+// StateTransmitter is a transmitter for messages describing additions to and
+// removals from a set of items, such as scheduled configs.  It tracks the
+// current set, keyed by each item's identity, and replays an addition for each
+// current item to receivers that subscribe later, before any live messages.
+//
+// Receivers provided at construction (via Publisher) see every message, so
+// replay only applies to those added with Subscribe.
+//
+// All StateTransmitter methods can be called concurrently.
+type StateTransmitter[K comparable, V comparable, M Message] struct {
+	// mu covers items and nextTicket, so that replays are consistent with
+	// live messages.  It is not held while messages are delivered, so a
+	// receiver that is slow to read does not hold up Subscribe or Items.  This
+	// is not embedded, as Lock has another meaning here.
+	mu sync.Mutex
+
+	// tx sends the messages
+	tx Transmitter[M]
+
+	// key gets the identity of an item
+	key func(V) K
+
+	// message creates a message for the addition or removal of an item
+	message func(item V, isAdd bool) M
+
+	// items is the current set of items
+	items map[K]V
+
+	// nextTicket is the ticket for the next change to the set
+	nextTicket uint64
+
+	// turn covers serving, and turnCond signals changes to it.  Each change
+	// to the set takes a ticket, and its messages are delivered once serving
+	// reaches that ticket, so receivers see changes in the order they were
+	// made.
+	turn     sync.Mutex
+	turnCond *sync.Cond
+	serving  uint64
+}
+
+// NewStateTransmitter creates a new StateTransmitter sending messages via tx.
+// The key function gives the identity of each item, and the message function
+// creates the message announcing an item's addition or removal.
+func NewStateTransmitter[K comparable, V comparable, M Message](tx Transmitter[M], key func(V) K, message func(item V, isAdd bool) M) *StateTransmitter[K, V, M] {
+	st := &StateTransmitter[K, V, M]{
+		tx:      tx,
+		key:     key,
+		message: message,
+		items:   map[K]V{},
+	}
+	st.turnCond = sync.NewCond(&st.turn)
+	return st
+}
+
+// Add adds an item to the set, notifying receivers.  Adding an item that is
+// already present does nothing.  Adding an item with the same key as a
+// different item first removes that item.
+func (st *StateTransmitter[K, V, M]) Add(item V) {
+	st.mu.Lock()
+
+	var messages []M
+	k := st.key(item)
+	if existing, found := st.items[k]; found {
+		if existing == item {
+			st.mu.Unlock()
+			return
+		}
+		messages = append(messages, st.message(existing, false))
+	}
+	st.items[k] = item
+	messages = append(messages, st.message(item, true))
+	st.notify(messages)
+}
+
+// Remove removes the item with the same key as the given item from the set,
+// notifying receivers.  Removing an item that is not present does nothing.
+func (st *StateTransmitter[K, V, M]) Remove(item V) {
+	st.mu.Lock()
+
+	k := st.key(item)
+	existing, found := st.items[k]
+	if !found {
+		st.mu.Unlock()
+		return
+	}
+	delete(st.items, k)
+	st.notify([]M{st.message(existing, false)})
+}
+
+// Set replaces the set of items, notifying receivers of the removals and
+// then the additions required to get there.
+func (st *StateTransmitter[K, V, M]) Set(items []V) {
+	st.mu.Lock()
+
+	desired := make(map[K]V, len(items))
+	for _, item := range items {
+		desired[st.key(item)] = item
+	}
+
+	added, removed := Diff(st.items, desired)
+	messages := make([]M, 0, len(removed)+len(added))
+	for _, item := range removed {
+		messages = append(messages, st.message(item, false))
+	}
+	for _, item := range added {
+		messages = append(messages, st.message(item, true))
+	}
+	st.items = desired
+	st.notify(messages)
+}
+
+// notify delivers the messages describing a change to the set, to the
+// receivers subscribed at the time of the change, after any earlier changes.
+// It must be called with mu held, and unlocks it before delivering.
+func (st *StateTransmitter[K, V, M]) notify(messages []M) {
+	links := st.tx.snapshot()
+	ticket := st.nextTicket
+	st.nextTicket++
+	st.mu.Unlock()
+
+	st.turn.Lock()
+	for st.serving != ticket {
+		st.turnCond.Wait()
+	}
+	st.turn.Unlock()
+
+	for _, message := range messages {
+		notifyLinks(links, message)
+	}
+
+	st.turn.Lock()
+	st.serving++
+	st.turnCond.Broadcast()
+	st.turn.Unlock()
+}
+
+// Items gets the current set of items.  The returned map is a copy.
+func (st *StateTransmitter[K, V, M]) Items() map[K]V {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	items := make(map[K]V, len(st.items))
+	for k, v := range st.items {
+		items[k] = v
+	}
+	return items
+}
+
+// Subscribe creates a new Receiver, as for Transmitter#Subscribe.  The
+// receiver first gets an addition message for each current item, followed by
+// live messages.  With the Block policy, live messages wait until the receiver
+// has read all of the additions; with other policies, the additions are
+// delivered like any other message, and may be dropped or queued.
+func (st *StateTransmitter[K, V, M]) Subscribe(opts ...Option) (Receiver[M], error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	replay := make([]M, 0, len(st.items))
+	for _, item := range st.items {
+		replay = append(replay, st.message(item, true))
+	}
+	return st.tx.subscribe(replay, opts)
+}
+
+// Unsubscribe removes a receiver, as for Transmitter#Unsubscribe.
+func (st *StateTransmitter[K, V, M]) Unsubscribe(rx Receiver[M]) {
+	st.tx.Unsubscribe(rx)
+}
+
+// Lock prevents further calls to Subscribe, as for Transmitter#Lock.
+func (st *StateTransmitter[K, V, M]) Lock() {
+	st.tx.Lock()
+}
+
+// Diff compares two sets of items, keyed by identity, returning the items
+// added in desired and the items removed from current.  An item whose key is
+// in both sets but whose value differs is both removed and added.  The
+// results are in no particular order.
+func Diff[K comparable, V comparable](current, desired map[K]V) (added, removed []V) {
+	for k, v := range current {
+		if d, found := desired[k]; !found || d != v {
+			removed = append(removed, v)
+		}
+	}
+	for k, v := range desired {
+		if c, found := current[k]; !found || c != v {
+			added = append(added, v)
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,176 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package subscriptions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type item struct {
+	name  string
+	value int
+}
+
+type itemChange struct {
+	isAdd bool
+	item  *item
+}
+
+func newItemTransmitter(receivers ...Receiver[itemChange]) *StateTransmitter[string, *item, itemChange] {
+	return NewStateTransmitter(NewTransmitter(receivers),
+		func(i *item) string { return i.name },
+		func(i *item, isAdd bool) itemChange { return itemChange{isAdd: isAdd, item: i} })
+}
+
+func TestStateAddRemove(t *testing.T) {
+	rx := NewReceiver[itemChange](WithPolicy(Unbounded))
+	st := newItemTransmitter(rx)
+
+	a1 := &item{"a", 1}
+	a2 := &item{"a", 2}
+	st.Add(a1)
+	st.Add(a1) // no change
+	st.Add(a2) // replaces a1
+	st.Remove(&item{"a", 0})
+	st.Remove(a1) // not present
+
+	require.Equal(t, itemChange{true, a1}, <-rx.Chan())
+	require.Equal(t, itemChange{false, a1}, <-rx.Chan())
+	require.Equal(t, itemChange{true, a2}, <-rx.Chan())
+	require.Equal(t, itemChange{false, a2}, <-rx.Chan())
+	require.Eventually(t, func() bool { return rx.Stats().Queued == 0 }, time.Second, time.Millisecond)
+	require.Empty(t, st.Items())
+}
+
+func TestStateReplay(t *testing.T) {
+	st := newItemTransmitter()
+
+	a, b, c := &item{"a", 1}, &item{"b", 1}, &item{"c", 1}
+	st.Add(a)
+	st.Add(b)
+
+	// a late subscriber, with a small buffer, gets the current state first
+	rx, err := st.Subscribe()
+	require.NoError(t, err)
+
+	// live messages wait for the replay to be read
+	sent := make(chan struct{})
+	go func() {
+		st.Add(c)
+		st.Remove(a)
+		close(sent)
+	}()
+
+	var replayed []*item
+	for i := 0; i < 2; i++ {
+		chg := <-rx.Chan()
+		require.True(t, chg.isAdd)
+		replayed = append(replayed, chg.item)
+	}
+	require.ElementsMatch(t, []*item{a, b}, replayed)
+	require.Equal(t, itemChange{true, c}, <-rx.Chan())
+	require.Equal(t, itemChange{false, a}, <-rx.Chan())
+	<-sent
+
+	// an unsubscribed receiver gets no further messages
+	st.Unsubscribe(rx)
+	st.Add(a)
+	require.Equal(t, 0, len(rx.Chan()))
+}
+
+func TestReplayBackpressure(t *testing.T) {
+	st := newItemTransmitter()
+	a, b := &item{"a", 1}, &item{"b", 1}
+	st.Add(a)
+
+	// a Block receiver holds up live messages until its replay is read
+	rx, err := st.Subscribe()
+	require.NoError(t, err)
+	sent := make(chan struct{})
+	go func() {
+		st.Add(b)
+		close(sent)
+	}()
+	select {
+	case <-sent:
+		require.Fail(t, "live message was not held behind the replay")
+	case <-time.After(10 * time.Millisecond):
+	}
+	require.Equal(t, itemChange{true, a}, <-rx.Chan())
+	require.Equal(t, itemChange{true, b}, <-rx.Chan())
+	<-sent
+
+	// a DropNewest receiver keeps only as much of the replay as fits
+	dropping, err := st.Subscribe(WithPolicy(DropNewest))
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), dropping.Stats().Dropped)
+	require.Equal(t, 1, len(dropping.Chan()))
+}
+
+func TestStateSlowReceiver(t *testing.T) {
+	rx := NewReceiver[itemChange]()
+	st := newItemTransmitter(rx)
+	a, b := &item{"a", 1}, &item{"b", 1}
+
+	// the receiver's buffer fills, so the second change waits for it
+	st.Add(a)
+	sent := make(chan struct{})
+	go func() {
+		st.Add(b)
+		close(sent)
+	}()
+	require.Eventually(t, func() bool { return len(st.Items()) == 2 }, time.Second, time.Millisecond)
+
+	// meanwhile, other subscribers are not held up
+	late, err := st.Subscribe(WithPolicy(Unbounded))
+	require.NoError(t, err)
+	require.Equal(t, 2, late.Stats().Queued)
+
+	require.Equal(t, itemChange{true, a}, <-rx.Chan())
+	require.Equal(t, itemChange{true, b}, <-rx.Chan())
+	<-sent
+}
+
+func TestStateSet(t *testing.T) {
+	rx := NewReceiver[itemChange](WithPolicy(Unbounded))
+	st := newItemTransmitter(rx)
+
+	a, b1, b2, c := &item{"a", 1}, &item{"b", 1}, &item{"b", 2}, &item{"c", 1}
+	st.Set([]*item{a, b1})
+	<-rx.Chan()
+	<-rx.Chan()
+
+	st.Set([]*item{b2, c})
+	var removed, added []*item
+	for i := 0; i < 4; i++ {
+		chg := <-rx.Chan()
+		if chg.isAdd {
+			added = append(added, chg.item)
+		} else {
+			// all removals precede additions
+			require.Empty(t, added)
+			removed = append(removed, chg.item)
+		}
+	}
+	require.ElementsMatch(t, []*item{a, b1}, removed)
+	require.ElementsMatch(t, []*item{b2, c}, added)
+	require.Equal(t, map[string]*item{"b": b2, "c": c}, st.Items())
+}
+
+func TestDiff(t *testing.T) {
+	added, removed := Diff(
+		map[string]int{"a": 1, "b": 2, "c": 3},
+		map[string]int{"b": 2, "c": 4, "d": 5})
+	require.ElementsMatch(t, []int{4, 5}, added)
+	require.ElementsMatch(t, []int{1, 3}, removed)
+
+	added, removed = Diff[string, int](nil, nil)
+	require.Empty(t, added)
+	require.Empty(t, removed)
+}
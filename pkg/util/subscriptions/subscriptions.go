@@ -9,26 +9,44 @@
 // Create Receivers with NewReceiver, and build a Transmitter to transmit to them.  Then
 // send messages with tx.Notify() and receive them with <-rx.Chan().
 //
+// Each receiver has a delivery Policy, determining what happens when it is not
+// keeping up with messages.  By default, Notify blocks until every receiver has
+// room for the message, so a single receiver that fails to read stalls the
+// transmitter.  Receivers that may fall behind should choose a policy that
+// drops or queues messages instead.  Each receiver counts the messages it has
+// dropped or queued, and subscriptions make these counts available, via the
+// "subscriptions" value group, for reporting by comp/core/subscriptionmon.
+//
+// Receivers built into a Transmitter are fixed when the Transmitter is created,
+// which for components is at Fx construction time.  Receivers can also be
+// added and removed at runtime with tx.Subscribe() and tx.Unsubscribe(), such
+// as for a streaming IPC endpoint.  A transmitting component that does not
+// support late subscribers can call tx.Lock() when it starts, after which
+// Subscribe returns ErrLocked.  All Transmitter methods can be called
+// concurrently.
+//
+// Messages often describe additions to and removals from a set, such as
+// scheduled configs.  A StateTransmitter tracks that set, so that receivers
+// subscribing at runtime first receive the current state, followed by live
+// updates.  Diff computes the additions and removals between two such sets.
+//
 // See the conventions documentation for a description of the component interface.
 //
 // Warning
 //
 // This package is not intended for high-bandwidth messaging such as metric
 // samples.  It use should be limited to events that occur on a per-minute
-// scale.
+// scale.  Use pkg/util/pipe for high-bandwidth data.
 package subscriptions
 
-import "go.uber.org/fx"
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
 
-/* XXX Future Improvements
- *
- * This package is really simple as-is, but there are lots of additional
- * concerns to address:
- *  - Handling concurrent access (Subscribe, Unsubscribe, and Notify race in this version)
- *  - Handling consumers that fail to read from their channel
- *  - "Locking" subscriptions, as most components only allow changes to descriptions before
- *    they start.
- */
+	"go.uber.org/fx"
+)
 
 // Message is the type of the message handled by a subscription point.  It can be any type.
 type Message interface{}
@@ -37,47 +55,180 @@ type Message interface{}
 //
 // A zero-valued receiver is valid, but will not receive messages.
 type Receiver[M Message] struct {
-	ch chan M
+	r *receiverState[M]
 }
 
-// NewReceiver creates a new Receiver.  Component-based subscriptions typically
-// use NewSubscriber, instead.
-func NewReceiver[M Message]() Receiver[M] {
+// NewReceiver creates a new Receiver, with the Block policy unless otherwise
+// specified.  Component-based subscriptions typically use NewSubscription,
+// instead.
+func NewReceiver[M Message](opts ...Option) Receiver[M] {
 	return Receiver[M]{
-		ch: make(chan M, 1),
+		r: newReceiverState[M](opts),
 	}
 }
 
 // Chan gets the channel from which messages for this subscription should be read
 func (s Receiver[M]) Chan() <-chan M {
-	return s.ch
+	if s.r == nil {
+		return nil
+	}
+	return s.r.ch
+}
+
+// Stats gets the receiver's delivery statistics.
+func (s Receiver[M]) Stats() Stats {
+	if s.r == nil {
+		return Stats{}
+	}
+	return s.r.stats()
 }
 
+// ErrLocked is returned from Transmitter#Subscribe once the transmitter has
+// been locked.
+var ErrLocked = errors.New("subscriptions are locked")
+
 // Transmitter defines a point where messages can be sent
+//
+// Transmitters must be created with NewTransmitter or Publisher#Transmitter.
+// Copies of a Transmitter share the same set of receivers.
 type Transmitter[M Message] struct {
-	chs []chan M
+	t *transmitterState[M]
+}
+
+// transmitterState is the shared state of a Transmitter.
+type transmitterState[M Message] struct {
+	// RWMutex covers all fields
+	sync.RWMutex
+
+	// publisher is the name of the transmitting component, if known
+	publisher string
+
+	// links connect to the current receivers.  This slice is replaced, never
+	// modified, so that Notify can send to a snapshot of it without holding
+	// the lock.
+	links []*link[M]
+
+	// locked is true once Lock has been called
+	locked bool
+}
+
+// link connects a transmitter to one of its receivers.
+type link[M Message] struct {
+	r *receiverState[M]
+
+	// sent counts the messages sent over this link, and is accessed
+	// atomically
+	sent uint64
 }
 
 // NewTransmitter creates a new Transmitter.  Component-based subscriptions
-// typically use NewPublisher, instead.
+// typically use Publisher, instead.
 //
 // This ignores any zero-valued receivers.
 func NewTransmitter[M Message](receivers []Receiver[M]) Transmitter[M] {
-	// get the receivers' channels, filtering out nils
-	chs := make([]chan M, 0, len(receivers))
+	return newTransmitter("", receivers)
+}
+
+// newTransmitter creates a new Transmitter for the named publisher.
+func newTransmitter[M Message](publisher string, receivers []Receiver[M]) Transmitter[M] {
+	// filter out zero-valued receivers
+	links := make([]*link[M], 0, len(receivers))
 	for _, rx := range receivers {
-		if rx.ch != nil {
-			chs = append(chs, rx.ch)
+		if rx.r != nil {
+			links = append(links, &link[M]{r: rx.r})
 		}
 	}
-	return Transmitter[M]{chs}
+	return Transmitter[M]{&transmitterState[M]{publisher: publisher, links: links}}
 }
 
-// Notify notifies all associated receivers of a new message.
+// Notify notifies all associated receivers of a new message, according to
+// each receiver's policy.
 func (sp Transmitter[M]) Notify(message M) {
-	for _, ch := range sp.chs {
-		ch <- message
+	notifyLinks(sp.snapshot(), message)
+}
+
+// snapshot gets the transmitter's current links.
+func (sp Transmitter[M]) snapshot() []*link[M] {
+	if sp.t == nil {
+		return nil
+	}
+
+	sp.t.RLock()
+	defer sp.t.RUnlock()
+	return sp.t.links
+}
+
+// notifyLinks sends a message over each of the given links.
+func notifyLinks[M Message](links []*link[M], message M) {
+	for _, l := range links {
+		l.r.send(message)
+		atomic.AddUint64(&l.sent, 1)
+	}
+}
+
+// Subscribe creates a new Receiver, with the Block policy unless otherwise
+// specified, and adds it to the transmitter.  The receiver gets messages
+// sent after Subscribe returns.  This returns ErrLocked if the transmitter
+// has been locked.
+//
+// Subscribers that stop reading must call Unsubscribe, or a Block receiver
+// will stall the transmitter.
+func (sp Transmitter[M]) Subscribe(opts ...Option) (Receiver[M], error) {
+	return sp.subscribe(nil, opts)
+}
+
+// subscribe implements Subscribe, delivering the given messages to the new
+// receiver before any others.
+func (sp Transmitter[M]) subscribe(replay []M, opts []Option) (Receiver[M], error) {
+	sp.t.Lock()
+	defer sp.t.Unlock()
+
+	if sp.t.locked {
+		return Receiver[M]{}, ErrLocked
+	}
+
+	rx := NewReceiver[M](opts...)
+	if len(replay) > 0 {
+		rx.r.replay(replay)
 	}
+	links := make([]*link[M], 0, len(sp.t.links)+1)
+	links = append(links, sp.t.links...)
+	sp.t.links = append(links, &link[M]{r: rx.r})
+	return rx, nil
+}
+
+// Unsubscribe removes a receiver from the transmitter.  No further messages
+// are sent to the receiver, including by calls to Notify that are already
+// blocked sending to it, but messages already in its channel remain there.
+// The channel is not closed.
+//
+// Unsubscribing a receiver that is not subscribed does nothing.  This is
+// allowed even after the transmitter is locked.
+func (sp Transmitter[M]) Unsubscribe(rx Receiver[M]) {
+	if rx.r == nil {
+		return
+	}
+
+	sp.t.Lock()
+	defer sp.t.Unlock()
+
+	for i, l := range sp.t.links {
+		if l.r == rx.r {
+			links := make([]*link[M], 0, len(sp.t.links)-1)
+			links = append(links, sp.t.links[:i]...)
+			sp.t.links = append(links, sp.t.links[i+1:]...)
+			l.r.close()
+			return
+		}
+	}
+}
+
+// Lock prevents further calls to Subscribe.  Transmitting components that do
+// not support late subscribers should call this when they start.
+func (sp Transmitter[M]) Lock() {
+	sp.t.Lock()
+	defer sp.t.Unlock()
+	sp.t.locked = true
 }
 
 // Subscription represents a component's request for a receiver of this type.
@@ -85,18 +236,52 @@ type Subscription[M Message] struct {
 	fx.Out
 
 	Receiver Receiver[M] `group:"subscriptions"`
+	Info     *Info       `group:"subscriptions"`
 }
 
-// NewSubscription creates a new subscription of the required type.
+// NewSubscription creates a new subscription of the required type, for the
+// named subscriber (typically the subscribing component's package path), with
+// the Block policy unless otherwise specified.
 //
 // A receiving component's constructor should call this function, capture the
 // Receiver field for later use, and return the Subscription.
-func NewSubscription[M Message]() Subscription[M] {
+func NewSubscription[M Message](subscriber string, opts ...Option) Subscription[M] {
+	rx := NewReceiver[M](append([]Option{WithSubscriber(subscriber)}, opts...)...)
 	return Subscription[M]{
-		Receiver: NewReceiver[M](),
+		Receiver: rx,
+		Info: &Info{
+			Subscriber:  subscriber,
+			MessageType: messageType[M](),
+			stats:       rx.Stats,
+		},
 	}
 }
 
+// Info describes a subscription, for monitoring.  A zero-valued Subscription
+// provides a nil Info, which should be ignored.
+type Info struct {
+	// Subscriber is the name of the subscribing component.
+	Subscriber string
+
+	// MessageType is the name of the message type, such as
+	// "sourcemgr.SourceChange".
+	MessageType string
+
+	// stats gets the receiver's statistics
+	stats func() Stats
+}
+
+// Stats gets the subscription's delivery statistics.
+func (i *Info) Stats() Stats {
+	return i.stats()
+}
+
+// messageType returns the name of the message type, qualified by its package
+// name.
+func messageType[M Message]() string {
+	return reflect.TypeOf((*M)(nil)).Elem().String()
+}
+
 // Publisher represents a component's request for a transmitter of this type.
 //
 // A component's constructor should take an object of this type as an argument
@@ -105,9 +290,17 @@ type Publisher[M Message] struct {
 	fx.In
 
 	Receivers []Receiver[M] `group:"subscriptions"`
+
+	// Registry, if present, records the transmitter for monitoring.
+	Registry *Registry `optional:"true"`
 }
 
-// Transmitter creates a transmitter for a publisher.
-func (p Publisher[M]) Transmitter() Transmitter[M] {
-	return NewTransmitter(p.Receivers)
+// Transmitter creates a transmitter for the named publisher (typically the
+// publishing component's package path).
+func (p Publisher[M]) Transmitter(publisher string) Transmitter[M] {
+	tx := newTransmitter(publisher, p.Receivers)
+	if p.Registry != nil {
+		p.Registry.register(tx.t)
+	}
+	return tx
 }
@@ -6,9 +6,12 @@
 package subscriptions
 
 import (
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/DataDog/dd-agent-comp-experiments/pkg/util/comptest"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/fx"
 )
@@ -26,6 +29,100 @@ func TestSubscribe(t *testing.T) {
 	require.Equal(t, 0, len(rx2.Chan()))
 }
 
+func TestDynamicSubscribe(t *testing.T) {
+	tx := NewTransmitter[string](nil)
+	tx.Notify("nobody listening")
+
+	rx, err := tx.Subscribe()
+	require.NoError(t, err)
+	tx.Notify("hello!")
+	require.Equal(t, "hello!", <-rx.Chan())
+
+	tx.Unsubscribe(rx)
+	tx.Notify("goodbye!")
+	require.Equal(t, 0, len(rx.Chan()))
+
+	// unsubscribing again does nothing
+	tx.Unsubscribe(rx)
+}
+
+func TestUnsubscribeUnblocksNotify(t *testing.T) {
+	tx := NewTransmitter[string](nil)
+	rx, err := tx.Subscribe()
+	require.NoError(t, err)
+
+	// the second message blocks, as nothing is reading
+	notified := make(chan struct{})
+	go func() {
+		tx.Notify("one")
+		tx.Notify("two")
+		close(notified)
+	}()
+	require.Eventually(t, func() bool { return len(rx.Chan()) == 1 }, time.Second, time.Millisecond)
+
+	tx.Unsubscribe(rx)
+	<-notified
+	require.Equal(t, "one", <-rx.Chan())
+}
+
+func TestLock(t *testing.T) {
+	rx := NewReceiver[string]()
+	tx := NewTransmitter([]Receiver[string]{rx})
+	tx.Lock()
+
+	_, err := tx.Subscribe()
+	require.ErrorIs(t, err, ErrLocked)
+
+	// static receivers can still be unsubscribed
+	tx.Unsubscribe(rx)
+	tx.Notify("hello")
+	require.Equal(t, 0, len(rx.Chan()))
+}
+
+func TestConcurrentSubscribe(t *testing.T) {
+	tx := NewTransmitter[int](nil)
+
+	stop := make(chan struct{})
+	notifierDone := make(chan struct{})
+	go func() {
+		defer close(notifierDone)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				tx.Notify(i)
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for s := 0; s < 20; s++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rx, err := tx.Subscribe()
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			// messages arrive in order
+			last := <-rx.Chan()
+			for i := 0; i < 10; i++ {
+				msg := <-rx.Chan()
+				assert.Greater(t, msg, last)
+				last = msg
+			}
+
+			// the notifier may be blocked on this receiver
+			tx.Unsubscribe(rx)
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	<-notifierDone
+}
+
 // ---- rx receives messages
 
 type RxComponent interface {
@@ -37,7 +134,7 @@ type receiver struct {
 }
 
 func newRx() (RxComponent, Subscription[string]) {
-	sub := NewSubscription[string]()
+	sub := NewSubscription[string]("rx")
 	return &receiver{rx: sub.Receiver}, sub
 }
 
@@ -57,7 +154,7 @@ type transmitter struct {
 
 func newTx(pub Publisher[string]) TxComponent {
 	return &transmitter{
-		tx: pub.Transmitter(),
+		tx: pub.Transmitter("tx"),
 	}
 }
 